@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	annotateRandom = "random"
+	annotateSample = "sample"
+	annotateClear  = "clear"
+)
+
+// annotationPath is the control endpoint of the record proxy that the
+// overlay posts to. It takes question, action (random, sample or clear)
+// and, for sample, column.
+const annotationPath = controlPathPrefix + "annotate"
+
+// annotate tags a question of the current page; the tags are written with
+// the next answers that are posted.
+func (session *recordingSession) annotate(question string, action string, column string) error {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	if !arrayContains(session.lastPage.questions, question) {
+		return fmt.Errorf("question '%s' is not on the current page", question)
+	}
+
+	switch action {
+	case annotateRandom, annotateClear:
+	case annotateSample:
+		if column == "" || strings.ContainsAny(column, ": \t") {
+			return fmt.Errorf("sample column '%s' must be a name without spaces or colons", column)
+		}
+	default:
+		return fmt.Errorf("unknown action '%s'", action)
+	}
+
+	delete(session.annotations.fromSample, question)
+	session.annotations.randomize = removeString(session.annotations.randomize, question)
+
+	if action == annotateRandom {
+		session.annotations.randomize = append(session.annotations.randomize, question)
+	} else if action == annotateSample {
+		if session.annotations.fromSample == nil {
+			session.annotations.fromSample = map[string]string{}
+		}
+		session.annotations.fromSample[question] = column
+	}
+
+	return nil
+}
+
+// describeAnnotations returns the tags of a step, e.g.
+// "q1: random, q2: sample age".
+func describeAnnotations(step replayStep) string {
+	tags := []string{}
+	for _, question := range step.randomize {
+		tags = append(tags, question+": random")
+	}
+	for question, column := range step.fromSample {
+		tags = append(tags, question+": sample "+column)
+	}
+	sort.Strings(tags)
+
+	if len(tags) == 0 {
+		return "no tags"
+	}
+
+	return strings.Join(tags, ", ")
+}
+
+func (session *recordingSession) handleAnnotation(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "POST" {
+		http.Error(response, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	request.ParseForm()
+	err := session.annotate(request.PostForm.Get("question"), request.PostForm.Get("action"), request.PostForm.Get("column"))
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	session.lock.Lock()
+	tags := describeAnnotations(session.annotations)
+	session.lock.Unlock()
+
+	printVerbose("recording", "Tags of current page: %s\n", tags)
+
+	response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	response.Write([]byte(tags))
+}
+
+var annotationOverlay = template.Must(template.New("overlay").Parse(`
+<div id="recording-overlay" data-path="{{.Path}}" style="position:fixed;right:8px;bottom:8px;z-index:2147483647;padding:6px;background:#fff;border:1px solid #999;font:12px sans-serif;color:#000">
+<b>Replay</b>
+<select id="recording-question">{{range .Questions}}<option>{{.}}</option>{{end}}</select>
+<button type="button" data-action="random">Randomize</button>
+<input id="recording-column" placeholder="sample column" size="12">
+<button type="button" data-action="sample">From sample</button>
+<button type="button" data-action="clear">Clear</button>
+<div id="recording-tags">{{.Tags}}</div>
+</div>
+<script>
+(function () {
+  var overlay = document.getElementById("recording-overlay");
+  overlay.addEventListener("click", function (event) {
+    var action = event.target.getAttribute("data-action");
+    if (!action) return;
+    var body = new URLSearchParams();
+    body.set("question", document.getElementById("recording-question").value);
+    body.set("action", action);
+    body.set("column", document.getElementById("recording-column").value);
+    fetch(overlay.getAttribute("data-path"), {method: "POST", body: body, credentials: "same-origin"})
+      .then(function (response) { return response.text(); })
+      .then(function (text) { document.getElementById("recording-tags").textContent = text; });
+  });
+})();
+</script>
+`))
+
+// injectAnnotationOverlay adds the overlay to tag questions to the end of
+// a page with questions.
+func injectAnnotationOverlay(body []byte, questions []string, tags string) ([]byte, error) {
+	index := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+	if index < 0 || len(questions) == 0 {
+		return body, nil
+	}
+
+	overlay := new(bytes.Buffer)
+	err := annotationOverlay.Execute(overlay, struct {
+		Questions []string
+		Tags      string
+		Path      string
+	}{questions, tags, annotationPath})
+	if err != nil {
+		return nil, err
+	}
+
+	result := append([]byte{}, body[:index]...)
+	result = append(result, overlay.Bytes()...)
+	return append(result, body[index:]...), nil
+}
+
+// applyReplayAnnotations returns the answers to post for a step, with the
+// tagged questions answered randomly or from the sample row.
+func applyReplayAnnotations(step replayStep, body *string, number int) (url.Values, error) {
+	if len(step.randomize) == 0 && len(step.fromSample) == 0 {
+		return step.answers, nil
+	}
+
+	answers := url.Values{}
+	for key, values := range step.answers {
+		answers[key] = append([]string{}, values...)
+	}
+
+	if len(step.randomize) > 0 {
+		generated, _, err := getInterviewResponse(body, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, question := range step.randomize {
+			removeQuestionAnswers(answers, question)
+			for key, values := range generated {
+				if isQuestionAnswerKey(key, question) {
+					answers[key] = values
+				}
+			}
+		}
+	}
+
+	for question, column := range step.fromSample {
+		if completeConfig.sample == nil {
+			return nil, &replayMismatchError{message: fmt.Sprintf(
+				"answer to %s comes from sample column '%s', but there is no sample file", question, column)}
+		}
+
+		value, ok := completeConfig.sample[number].value(column)
+		if !ok {
+			return nil, &replayMismatchError{message: fmt.Sprintf(
+				"answer to %s comes from sample column '%s', which is not in the sample file", question, column)}
+		}
+
+		isCategory := step.questionType == qTypeCategory || len(answers["answer-"+question+"-m"]) > 0
+		isMulti := isMultiCodedAnswer(answers, question)
+		removeQuestionAnswers(answers, question)
+
+		switch {
+		case isCategory && isMulti:
+			answers.Set("answer-"+question+"-m", value)
+			answers.Set("answer-"+question+"-"+value, question+"-"+value)
+		case isCategory:
+			answers.Set("answer-"+question+"-m", value)
+			answers.Set("answer-"+question, question+"-"+value)
+		default:
+			answers.Set("answer-"+question, value)
+		}
+	}
+
+	return answers, nil
+}
+
+// isMultiCodedAnswer tells if the recorded answer to question has a
+// checkbox per code (answer-q1-3=q1-3) rather than a single answer-q1.
+func isMultiCodedAnswer(answers url.Values, question string) bool {
+	prefix := "answer-" + question + "-"
+	for key, values := range answers {
+		code := strings.TrimPrefix(key, prefix)
+		if code == key || code == "m" {
+			continue
+		}
+
+		for _, value := range values {
+			if value == question+"-"+code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isQuestionAnswerKey tells if a posted key is part of the answer to
+// question, e.g. answer-q1, answer-q1-m and answer-q1-3 for q1.
+func isQuestionAnswerKey(key string, question string) bool {
+	prefix := "answer-" + question
+	if key == prefix {
+		return true
+	}
+
+	if !strings.HasPrefix(key, prefix+"-") {
+		return false
+	}
+
+	suffix := strings.TrimPrefix(key, prefix+"-")
+	_, err := strconv.Atoi(suffix)
+	return suffix == "m" || err == nil
+}
+
+func removeQuestionAnswers(answers url.Values, question string) {
+	for key := range answers {
+		if isQuestionAnswerKey(key, question) {
+			delete(answers, key)
+		}
+	}
+}
+
+func removeString(list []string, value string) []string {
+	result := []string{}
+	for _, item := range list {
+		if item != value {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
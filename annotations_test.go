@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateWritesTagsWithNextStep(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	output := new(bytes.Buffer)
+	session := newRecordingSession(output)
+	session.lastPage = replayStep{questions: []string{"q1", "q2"}}
+
+	assert.NoError(session.annotate("q1", annotateRandom, ""))
+	assert.NoError(session.annotate("q2", annotateSample, "age"))
+	assert.Error(session.annotate("q3", annotateRandom, ""))
+	assert.Error(session.annotate("q1", annotateSample, "two words"))
+	assert.Error(session.annotate("q1", "unknown", ""))
+	assert.Equal("q1: random, q2: sample age", describeAnnotations(session.annotations))
+
+	request := httptest.NewRequest("POST", "/Interview/Post", strings.NewReader("answer-q1=a&answer-q2=b"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	session.record(request)
+
+	// tags are only for the page they were set on
+	request = httptest.NewRequest("POST", "/Interview/Post", strings.NewReader("answer-q1=c"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	session.record(request)
+
+	steps := parseReplaySteps(output.String())
+	assert.Len(steps, 2)
+	assert.Equal([]string{"q1"}, steps[0].randomize)
+	assert.Equal(map[string]string{"q2": "age"}, steps[0].fromSample)
+	assert.Empty(steps[1].randomize)
+	assert.Empty(steps[1].fromSample)
+}
+
+func TestAnnotateClearsTag(t *testing.T) {
+	assert := assert.New(t)
+
+	session := newRecordingSession(nil)
+	session.lastPage = replayStep{questions: []string{"q1"}}
+
+	assert.NoError(session.annotate("q1", annotateRandom, ""))
+	assert.NoError(session.annotate("q1", annotateClear, ""))
+	assert.Equal("no tags", describeAnnotations(session.annotations))
+}
+
+func TestHandleAnnotation(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	session := newRecordingSession(nil)
+	session.lastPage = replayStep{questions: []string{"q1"}}
+
+	form := url.Values{"question": {"q1"}, "action": {"sample"}, "column": {"age"}}
+	request := httptest.NewRequest("POST", annotationPath, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+
+	session.handleAnnotation(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("q1: sample age", response.Body.String())
+
+	response = httptest.NewRecorder()
+	session.handleAnnotation(response, httptest.NewRequest("GET", annotationPath, nil))
+	assert.Equal(http.StatusMethodNotAllowed, response.Code)
+}
+
+func TestInjectAnnotationOverlay(t *testing.T) {
+	assert := assert.New(t)
+
+	body := []byte("<html><body><form></form></BODY></html>")
+
+	result, err := injectAnnotationOverlay(body, []string{"q1", "q2"}, "q1: random")
+	assert.NoError(err)
+	assert.True(bytes.HasPrefix(result, []byte("<html><body><form></form>")))
+	assert.True(bytes.HasSuffix(result, []byte("</BODY></html>")))
+	assert.Contains(string(result), "<option>q2</option>")
+	assert.Contains(string(result), "q1: random")
+	assert.Contains(string(result), annotationPath)
+
+	result, err = injectAnnotationOverlay(body, nil, "no tags")
+	assert.NoError(err)
+	assert.Equal(body, result)
+}
+
+func TestApplyReplayAnnotationsRandomizesAnswers(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+	completeConfig = &completeConfiguration{}
+
+	stringForBothTemplates(t, "single-coded", func(doc string) {
+		step := replayStep{
+			answers:   url.Values{"answer-q1": {"q1-99"}, "answer-q1-m": {"99"}, "button-next": {"Next"}},
+			randomize: []string{"q1"},
+		}
+
+		answers, err := applyReplayAnnotations(step, &doc, 0)
+		assert.NoError(err)
+
+		assert.NotEqual("99", answers.Get("answer-q1-m"))
+		assert.Equal("q1-"+answers.Get("answer-q1-m"), answers.Get("answer-q1"))
+		assert.Equal("Next", answers.Get("button-next"))
+
+		// the step itself is left alone
+		assert.Equal("99", step.answers.Get("answer-q1-m"))
+	})
+}
+
+func TestApplyReplayAnnotationsTakesAnswersFromSample(t *testing.T) {
+	assert := assert.New(t)
+
+	sample, err := parseSample(strings.NewReader("respondentkey,Age,brand\nr1,42,3\nr2,18,5\n"), "respondentkey")
+	assert.NoError(err)
+	completeConfig = &completeConfiguration{sample: sample}
+
+	step := replayStep{
+		answers:    url.Values{"answer-q1": {"30"}, "answer-q2-m": {"1"}, "answer-q2": {"q2-1"}},
+		fromSample: map[string]string{"q1": "age", "q2": "brand"},
+	}
+
+	answers, err := applyReplayAnnotations(step, nil, 1)
+	assert.NoError(err)
+	assert.Equal(url.Values{"answer-q1": {"18"}, "answer-q2-m": {"5"}, "answer-q2": {"q2-5"}}, answers)
+
+	step = replayStep{
+		questionType: qTypeCategory,
+		answers:      url.Values{"answer-q2-m": {"1", "2"}, "answer-q2-1": {"q2-1"}, "answer-q2-2": {"q2-2"}},
+		fromSample:   map[string]string{"q2": "brand"},
+	}
+
+	answers, err = applyReplayAnnotations(step, nil, 1)
+	assert.NoError(err)
+	assert.Equal(url.Values{"answer-q2-m": {"5"}, "answer-q2-5": {"q2-5"}}, answers, "multi-coded answer is posted per code")
+
+	step.fromSample = map[string]string{"q1": "missing"}
+	_, err = applyReplayAnnotations(step, nil, 1)
+	var mismatch *replayMismatchError
+	assert.ErrorAs(err, &mismatch)
+
+	completeConfig = &completeConfiguration{}
+	_, err = applyReplayAnnotations(step, nil, 1)
+	assert.ErrorAs(err, &mismatch)
+}
+
+func TestIsQuestionAnswerKey(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isQuestionAnswerKey("answer-q1", "q1"))
+	assert.True(isQuestionAnswerKey("answer-q1-m", "q1"))
+	assert.True(isQuestionAnswerKey("answer-q1-3", "q1"))
+	assert.False(isQuestionAnswerKey("answer-q10", "q1"))
+	assert.False(isQuestionAnswerKey("answer-q10-m", "q1"))
+	assert.False(isQuestionAnswerKey("button-next", "q1"))
+}
+
+func TestPageServedAddsOverlay(t *testing.T) {
+	assert := assert.New(t)
+
+	page, err := getHTMLString("pages/chicago/single-coded.html")
+	assert.NoError(err)
+
+	session := newRecordingSession(nil)
+	session.overlay = true
+
+	response := &http.Response{
+		Header: http.Header{"Content-Type": {"text/html"}},
+		Body:   ioutil.NopCloser(strings.NewReader(page)),
+	}
+	assert.NoError(session.pageServed(response))
+
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Contains(string(body), `id="recording-overlay"`)
+	assert.Contains(string(body), "<option>q1</option>")
+	assert.Equal(int64(len(body)), response.ContentLength)
+}
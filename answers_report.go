@@ -0,0 +1,443 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	texttemplate "text/template"
+	"time"
+)
+
+type questionStats struct {
+	id           string
+	questionType string
+	answered     int
+	categories   map[string]int
+	numbers      []float64
+	lengths      []int
+}
+
+// postedAnswers are the answers to a page that the interview has not
+// accepted yet.
+type postedAnswers struct {
+	page    pageInfo
+	answers url.Values
+}
+
+// answersReport collects every answer the interview accepted, per
+// question, and writes their distributions with the statistics of the run.
+type answersReport struct {
+	lock    sync.Mutex
+	path    string
+	started time.Time
+
+	pending    map[int]pageInfo
+	posted     map[int]postedAnswers
+	questions  map[string]*questionStats
+	pageTypes  map[string]int
+	pageCount  int
+	durations  []time.Duration
+	interviews int
+}
+
+func newAnswersReport(path string) *answersReport {
+	return &answersReport{
+		path:      path,
+		started:   time.Now(),
+		pending:   make(map[int]pageInfo),
+		posted:    make(map[int]postedAnswers),
+		questions: make(map[string]*questionStats),
+		pageTypes: make(map[string]int),
+	}
+}
+
+func (report *answersReport) handleEvent(event interviewEvent) {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	switch event.Event {
+	case eventQuestionDetected:
+		// a new page means the answers to the previous one were accepted
+		report.acceptAnswers(event.Interview)
+		report.pending[event.Interview] = pageInfo{questionType: event.QuestionType, questions: event.Questions}
+		report.pageTypes[event.QuestionType]++
+		report.pageCount++
+	case eventAnswersPosted:
+		report.acceptAnswers(event.Interview)
+
+		// navigation (e.g. back) is posted without detecting a question first
+		page, ok := report.pending[event.Interview]
+		if !ok {
+			return
+		}
+		delete(report.pending, event.Interview)
+		report.posted[event.Interview] = postedAnswers{page: page, answers: event.Answers}
+	case eventValidationError:
+		delete(report.posted, event.Interview)
+	case eventInterviewCompleted, eventInterviewAbandoned, eventInterviewErrored:
+		if event.Event != eventInterviewErrored {
+			report.acceptAnswers(event.Interview)
+		}
+		delete(report.posted, event.Interview)
+		delete(report.pending, event.Interview)
+		report.durations = append(report.durations, event.duration)
+		report.interviews++
+	}
+}
+
+func (report *answersReport) acceptAnswers(interview int) {
+	if posted, ok := report.posted[interview]; ok {
+		delete(report.posted, interview)
+		report.addAnswers(posted.page, posted.answers)
+	}
+}
+
+func (report *answersReport) addAnswers(page pageInfo, answers url.Values) {
+	for _, question := range page.questions {
+		stats, ok := report.questions[question]
+		if !ok {
+			stats = &questionStats{id: question, questionType: page.questionType, categories: make(map[string]int)}
+			report.questions[question] = stats
+		}
+
+		switch page.questionType {
+		case qTypeCategory:
+			codes := answers["answer-"+question+"-m"]
+			if len(codes) > 0 {
+				stats.answered++
+			}
+			for _, code := range codes {
+				stats.categories[code]++
+			}
+		case qTypeNumber:
+			if value, err := strconv.ParseFloat(answers.Get("answer-"+question), 64); err == nil {
+				stats.answered++
+				stats.numbers = append(stats.numbers, value)
+			}
+		case qTypeOpenSingle, qTypeOpenMulti:
+			if text, ok := answers["answer-"+question]; ok {
+				stats.answered++
+				stats.lengths = append(stats.lengths, len([]rune(text[0])))
+			}
+		}
+	}
+}
+
+type reportRow struct {
+	Label   string
+	Count   int
+	Percent float64
+}
+
+type reportQuestion struct {
+	ID       string
+	Type     string
+	Answered int
+	Rows     []reportRow
+	Summary  string
+}
+
+type reportError struct {
+	Kind    string
+	Count   int
+	Example string
+}
+
+type reportData struct {
+	Command    string
+	URL        string
+	Started    string
+	Duration   string
+	Target     int
+	Completed  int
+	Successful int
+	Errored    int
+	Abandoned  int64
+	Retried    int64
+	Interviews int
+	Average    string
+	Errors     []reportError
+	PageTypes  []reportRow
+	Questions  []reportQuestion
+}
+
+func questionNumber(id string) int {
+	number, _ := strconv.Atoi(strings.TrimPrefix(id, "q"))
+	return number
+}
+
+func percentage(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(total)
+}
+
+func (report *answersReport) data() reportData {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	result := reportData{
+		Command:    globalConfig.command,
+		Started:    report.started.Format("2006-01-02 15:04:05"),
+		Duration:   time.Since(report.started).Round(time.Second).String(),
+		Interviews: report.interviews,
+	}
+
+	for _, group := range collectedErrors.summary() {
+		result.Errors = append(result.Errors, reportError{Kind: group.kind, Count: group.count, Example: group.example.Error()})
+	}
+
+	if completeConfig != nil && currentStatus != nil {
+		result.URL = completeConfig.interviewURL
+		result.Target = completeConfig.target
+		result.Completed = currentStatus.completed
+		result.Successful = getSuccessfulCount()
+		result.Errored = currentStatus.errored
+		result.Abandoned = atomic.LoadInt64(&currentStatus.abandoned)
+		result.Retried = atomic.LoadInt64(&currentStatus.retried)
+	}
+
+	if len(report.durations) > 0 {
+		var total time.Duration
+		for _, duration := range report.durations {
+			total += duration
+		}
+		result.Average = (total / time.Duration(len(report.durations))).Round(time.Millisecond).String()
+	}
+
+	for questionType, count := range report.pageTypes {
+		result.PageTypes = append(result.PageTypes, reportRow{Label: questionType, Count: count, Percent: percentage(count, report.pageCount)})
+	}
+	sort.Slice(result.PageTypes, func(i, j int) bool { return result.PageTypes[i].Label < result.PageTypes[j].Label })
+
+	for _, stats := range report.questions {
+		result.Questions = append(result.Questions, stats.report())
+	}
+	sort.Slice(result.Questions, func(i, j int) bool {
+		return questionNumber(result.Questions[i].ID) < questionNumber(result.Questions[j].ID)
+	})
+
+	return result
+}
+
+func (stats *questionStats) report() reportQuestion {
+	result := reportQuestion{ID: stats.id, Type: stats.questionType, Answered: stats.answered}
+
+	switch stats.questionType {
+	case qTypeCategory:
+		codes := []string{}
+		for code := range stats.categories {
+			codes = append(codes, code)
+		}
+		sort.Slice(codes, func(i, j int) bool {
+			a, errA := strconv.Atoi(codes[i])
+			b, errB := strconv.Atoi(codes[j])
+			if errA != nil || errB != nil {
+				return codes[i] < codes[j]
+			}
+			return a < b
+		})
+
+		for _, code := range codes {
+			count := stats.categories[code]
+			result.Rows = append(result.Rows, reportRow{Label: code, Count: count, Percent: percentage(count, stats.answered)})
+		}
+	case qTypeNumber:
+		if len(stats.numbers) > 0 {
+			min, max, mean := describe(stats.numbers)
+			result.Summary = fmt.Sprintf("min %g, max %g, mean %.2f", min, max, mean)
+			result.Rows = histogramRows(stats.numbers, min, max)
+		}
+	case qTypeOpenSingle, qTypeOpenMulti:
+		if len(stats.lengths) > 0 {
+			lengths := []float64{}
+			for _, length := range stats.lengths {
+				lengths = append(lengths, float64(length))
+			}
+			min, max, mean := describe(lengths)
+			result.Summary = fmt.Sprintf("length: min %g, max %g, mean %.1f characters", min, max, mean)
+		}
+	}
+
+	return result
+}
+
+func describe(values []float64) (float64, float64, float64) {
+	min, max, sum := math.Inf(1), math.Inf(-1), 0.0
+
+	for _, value := range values {
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+		sum += value
+	}
+
+	return min, max, sum / float64(len(values))
+}
+
+// histogramRows puts the values in at most ten bins of equal width.
+func histogramRows(values []float64, min float64, max float64) []reportRow {
+	bins := 10
+	width := (max - min) / float64(bins)
+
+	if max-min < float64(bins) {
+		// small (integer) ranges get a bin per value
+		bins = int(max-min) + 1
+		width = 1
+	}
+
+	counts := make([]int, bins)
+	for _, value := range values {
+		bin := int((value - min) / width)
+		if bin >= bins {
+			bin = bins - 1
+		}
+		counts[bin]++
+	}
+
+	rows := []reportRow{}
+	for i, count := range counts {
+		label := fmt.Sprintf("%g", min+float64(i)*width)
+		if width != 1 {
+			label = fmt.Sprintf("%g – %g", min+float64(i)*width, min+float64(i+1)*width)
+		}
+		rows = append(rows, reportRow{Label: label, Count: count, Percent: percentage(count, len(values))})
+	}
+
+	return rows
+}
+
+func (report *answersReport) write() error {
+	file, err := os.Create(report.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data := report.data()
+
+	if strings.EqualFold(filepath.Ext(report.path), ".md") {
+		return writeMarkdownReport(file, data)
+	}
+
+	return htmltemplate.Must(htmltemplate.New("report").Parse(htmlReportTemplate)).Execute(file, data)
+}
+
+func writeMarkdownReport(output io.Writer, data reportData) error {
+	return texttemplate.Must(texttemplate.New("report").Parse(markdownReportTemplate)).Execute(output, data)
+}
+
+const markdownReportTemplate = `# Run report
+
+| | |
+|---|---|
+| Command | {{.Command}} |
+| URL | {{.URL}} |
+| Started | {{.Started}} |
+| Duration | {{.Duration}} |
+| Completed | {{.Completed}} of {{.Target}} |
+| Successful | {{.Successful}} |
+| Errored | {{.Errored}} |
+| Abandoned | {{.Abandoned}} |
+| Retried requests | {{.Retried}} |
+{{- if .Average}}
+| Average interview duration | {{.Average}} |
+{{- end}}
+{{if .Errors}}
+## Errors
+
+| Type | Count | Example |
+|---|---|---|
+{{- range .Errors}}
+| {{.Kind}} | {{.Count}} | {{.Example}} |
+{{- end}}
+{{end}}
+## Question types
+
+| Type | Pages | % |
+|---|---|---|
+{{- range .PageTypes}}
+| {{.Label}} | {{.Count}} | {{printf "%.1f" .Percent}} |
+{{- end}}
+{{range .Questions}}
+## {{.ID}} ({{.Type}})
+
+Answered {{.Answered}} times.{{if .Summary}} {{.Summary}}.{{end}}
+{{if .Rows}}
+| Answer | Count | % |
+|---|---|---|
+{{- range .Rows}}
+| {{.Label}} | {{.Count}} | {{printf "%.1f" .Percent}} |
+{{- end}}
+{{end -}}
+{{end -}}
+`
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Run report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+.bar { background: #4a90d9; height: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Run report</h1>
+<table>
+<tr><th>Command</th><td>{{.Command}}</td></tr>
+<tr><th>URL</th><td>{{.URL}}</td></tr>
+<tr><th>Started</th><td>{{.Started}}</td></tr>
+<tr><th>Duration</th><td>{{.Duration}}</td></tr>
+<tr><th>Completed</th><td>{{.Completed}} of {{.Target}}</td></tr>
+<tr><th>Successful</th><td>{{.Successful}}</td></tr>
+<tr><th>Errored</th><td>{{.Errored}}</td></tr>
+<tr><th>Abandoned</th><td>{{.Abandoned}}</td></tr>
+<tr><th>Retried requests</th><td>{{.Retried}}</td></tr>
+{{- if .Average}}
+<tr><th>Average interview duration</th><td>{{.Average}}</td></tr>
+{{- end}}
+</table>
+{{if .Errors}}
+<h2>Errors</h2>
+<table>
+<tr><th>Type</th><th>Count</th><th>Example</th></tr>
+{{- range .Errors}}
+<tr><td>{{.Kind}}</td><td>{{.Count}}</td><td>{{.Example}}</td></tr>
+{{- end}}
+</table>
+{{end}}
+<h2>Question types</h2>
+<table>
+<tr><th>Type</th><th>Pages</th><th>%</th></tr>
+{{- range .PageTypes}}
+<tr><td>{{.Label}}</td><td>{{.Count}}</td><td>{{printf "%.1f" .Percent}}</td></tr>
+{{- end}}
+</table>
+{{range .Questions}}
+<h2>{{.ID}} ({{.Type}})</h2>
+<p>Answered {{.Answered}} times.{{if .Summary}} {{.Summary}}.{{end}}</p>
+{{- if .Rows}}
+<table>
+<tr><th>Answer</th><th>Count</th><th>%</th><th></th></tr>
+{{- range .Rows}}
+<tr><td>{{.Label}}</td><td>{{.Count}}</td><td>{{printf "%.1f" .Percent}}</td><td><div class="bar" style="width: {{printf "%.0f" .Percent}}px"></div></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnswersReport(t *testing.T) {
+	numberOfRequests := 0
+	setupMocking(t, "pages/test-interview", &numberOfRequests)
+	collectedErrors = &errorCollector{}
+
+	assert := assert.New(t)
+
+	report := newAnswersReport("")
+	eventListeners = []eventListener{report.handleEvent}
+	defer func() { eventListeners = nil }()
+
+	tracker := newInterviewTracker(0, "")
+	err := performInterview(http.Client{}, &completeConfig.interviewURL, tracker)
+	tracker.finish(err)
+	assert.NoError(err)
+
+	data := report.data()
+	assert.Equal(1, data.Interviews)
+	assert.NotEmpty(data.PageTypes)
+	assert.Len(data.Questions, 10)
+
+	for i, question := range data.Questions {
+		assert.Equal(1, question.Answered, question.ID)
+
+		if i > 0 {
+			assert.True(questionNumber(data.Questions[i-1].ID) < questionNumber(question.ID), "questions are sorted")
+		}
+		if question.Type == qTypeCategory {
+			assert.NotEmpty(question.Rows)
+		}
+		if question.Type == qTypeOpenSingle || question.Type == qTypeOpenMulti {
+			assert.Contains(question.Summary, "length")
+		}
+	}
+
+	var markdown bytes.Buffer
+	assert.NoError(writeMarkdownReport(&markdown, data))
+	assert.Contains(markdown.String(), "## q10 (")
+
+	dir, err := ioutil.TempDir("", "report")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	report.path = filepath.Join(dir, "report.html")
+	assert.NoError(report.write())
+
+	content, err := ioutil.ReadFile(report.path)
+	assert.NoError(err)
+	assert.Contains(string(content), "<h2>q10 (")
+}
+
+func TestAnswersReportSkipsRejectedAnswers(t *testing.T) {
+	assert := assert.New(t)
+
+	report := newAnswersReport("")
+	for _, event := range []interviewEvent{
+		{Event: eventInterviewStarted},
+		{Event: eventQuestionDetected, QuestionType: qTypeCategory, Questions: []string{"q1"}},
+		{Event: eventAnswersPosted, Answers: url.Values{"answer-q1-m": {"1"}, "answer-q1": {"q1-1"}}},
+		{Event: eventQuestionDetected, QuestionType: qTypeNumber, Questions: []string{"q2"}},
+		{Event: eventAnswersPosted, Answers: url.Values{"answer-q2": {"1000"}}},
+		{Event: eventValidationError},
+		{Event: eventInterviewErrored},
+	} {
+		report.handleEvent(event)
+	}
+
+	assert.Equal(1, report.questions["q1"].answered)
+	assert.Equal(1, report.questions["q1"].categories["1"])
+	assert.Nil(report.questions["q2"], "rejected answers are not counted")
+}
+
+func TestHistogramRows(t *testing.T) {
+	assert := assert.New(t)
+
+	rows := histogramRows([]float64{1, 2, 2, 3}, 1, 3)
+	assert.Equal([]reportRow{
+		{Label: "1", Count: 1, Percent: 25},
+		{Label: "2", Count: 2, Percent: 50},
+		{Label: "3", Count: 1, Percent: 25},
+	}, rows)
+
+	rows = histogramRows([]float64{0, 50, 100}, 0, 100)
+	assert.Len(rows, 10)
+	assert.Equal(1, rows[0].Count)
+	assert.Equal(1, rows[5].Count)
+	assert.Equal(1, rows[9].Count)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type artefactPage struct {
+	url  string
+	body string
+}
+
+type interviewArtefacts struct {
+	pages []artefactPage
+	steps []string
+}
+
+var unsafePathCharacters = regexp.MustCompile("[^A-Za-z0-9_.-]+")
+
+// newArtefactCollector creates a listener that keeps the last maxPages pages
+// and all requests of every running interview, and writes them to a folder
+// in dir when the interview errors.
+func newArtefactCollector(dir string, maxPages int) eventListener {
+	running := make(map[int]*interviewArtefacts)
+	var lock sync.Mutex
+
+	return func(event interviewEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		artefacts, ok := running[event.Interview]
+		if !ok {
+			artefacts = &interviewArtefacts{}
+			running[event.Interview] = artefacts
+		}
+
+		switch event.Event {
+		case eventPageFetched:
+			step := fmt.Sprintf("%s %s -> %d %s", event.Method, event.URL, event.Status, event.ResponseURL)
+			if event.Error != "" {
+				step += " (" + event.Error + ")"
+			}
+			artefacts.steps = append(artefacts.steps, step)
+
+			if event.body != nil && maxPages > 0 {
+				artefacts.pages = append(artefacts.pages, artefactPage{url: event.ResponseURL, body: *event.body})
+				if len(artefacts.pages) > maxPages {
+					artefacts.pages = artefacts.pages[1:]
+				}
+			}
+		case eventAnswersPosted:
+			artefacts.steps = append(artefacts.steps, fmt.Sprintf("POST %s with %s", event.URL, event.Answers.Encode()))
+		case eventQuestionDetected:
+			artefacts.steps = append(artefacts.steps, fmt.Sprintf("question %s (%s)", strings.Join(event.Questions, ", "), event.QuestionType))
+		case eventInterviewErrored:
+			err := writeArtefacts(dir, event, artefacts)
+			if err != nil {
+				printVerbose("artefacts", "Could not save artefacts for interview %d: %v\n", event.Interview, err)
+			}
+			delete(running, event.Interview)
+		case eventInterviewCompleted, eventInterviewAbandoned:
+			delete(running, event.Interview)
+		}
+	}
+}
+
+func getArtefactsFolder(dir string, event interviewEvent) string {
+	name := fmt.Sprintf("interview-%04d", event.Interview)
+	if event.RespondentKey != "" {
+		name += "-" + unsafePathCharacters.ReplaceAllString(event.RespondentKey, "_")
+	}
+
+	return filepath.Join(dir, name)
+}
+
+func writeArtefacts(dir string, event interviewEvent, artefacts *interviewArtefacts) error {
+	folder := getArtefactsFolder(dir, event)
+
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("interview: %d\nrespondent key: %s\nerror: %s\n\n%s\n",
+		event.Interview, event.RespondentKey, event.Error, strings.Join(artefacts.steps, "\n"))
+
+	if err := ioutil.WriteFile(filepath.Join(folder, "error.txt"), []byte(summary), 0644); err != nil {
+		return err
+	}
+
+	for i, page := range artefacts.pages {
+		// the last page is the one that caused the error
+		fileName := fmt.Sprintf("page-%02d.html", i+1)
+		content := fmt.Sprintf("<!-- %s -->\n%s", page.url, page.body)
+
+		if err := ioutil.WriteFile(filepath.Join(folder, fileName), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	printVerbose("artefacts", "Saved artefacts for interview %d to %s\n", event.Interview, folder)
+
+	return nil
+}
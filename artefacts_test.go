@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtefactsOfFailedInterview(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	dir, err := ioutil.TempDir("", "artefacts")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	eventListeners = []eventListener{newArtefactCollector(dir, 2)}
+	defer func() { eventListeners = nil }()
+
+	tracker := newInterviewTracker(12, "key/12")
+	tracker.start()
+
+	for i := 1; i <= 3; i++ {
+		body := fmt.Sprintf("<html>page %d</html>", i)
+		pageURL := fmt.Sprintf("http://localhost/page%d", i)
+
+		tracker.emit(interviewEvent{Event: eventAnswersPosted, URL: pageURL, Answers: url.Values{"answer-q1": []string{"1"}}})
+		tracker.fetch("GET", &pageURL, func() (pageContent, error) {
+			return pageContent{body: &body, url: &pageURL, status: 200}, nil
+		})
+	}
+
+	tracker.finish(errAnswerRejected)
+
+	folder := filepath.Join(dir, "interview-0012-key_12")
+	files, err := ioutil.ReadDir(folder)
+	assert.NoError(err)
+	assert.Len(files, 3)
+
+	page, err := ioutil.ReadFile(filepath.Join(folder, "page-02.html"))
+	assert.NoError(err)
+	assert.Contains(string(page), "page 3")
+
+	summary, err := ioutil.ReadFile(filepath.Join(folder, "error.txt"))
+	assert.NoError(err)
+	assert.Contains(string(summary), errAnswerRejected.Error())
+	assert.Contains(string(summary), "answer-q1=1")
+}
+
+func TestNoArtefactsForCompletedInterview(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	dir, err := ioutil.TempDir("", "artefacts")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	eventListeners = []eventListener{newArtefactCollector(dir, 2)}
+	defer func() { eventListeners = nil }()
+
+	tracker := newInterviewTracker(1, "")
+	tracker.start()
+	tracker.finish(nil)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	assert.Empty(files)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	reportFormatJUnit = "junit"
+	reportFormatTAP   = "tap"
+)
+
+type reportStep struct {
+	name     string
+	started  time.Time
+	duration time.Duration
+	failure  string
+	closed   bool
+}
+
+type reportCase struct {
+	number        int
+	respondentKey string
+	duration      time.Duration
+	status        string
+	failure       string
+	steps         []*reportStep
+}
+
+func (testCase *reportCase) name() string {
+	if testCase.respondentKey != "" {
+		return fmt.Sprintf("interview %d (%s)", testCase.number, testCase.respondentKey)
+	}
+	return fmt.Sprintf("interview %d", testCase.number)
+}
+
+type ciReport struct {
+	path   string
+	format string
+	steps  bool
+
+	running  map[int]*reportCase
+	finished []*reportCase
+	started  time.Time
+	lock     sync.Mutex
+}
+
+func newCIReport(path string, format string, steps bool) *ciReport {
+	return &ciReport{
+		path:    path,
+		format:  format,
+		steps:   steps,
+		running: make(map[int]*reportCase),
+		started: time.Now(),
+	}
+}
+
+func (report *ciReport) handleEvent(event interviewEvent) {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	if event.Event == eventInterviewStarted {
+		report.running[event.Interview] = &reportCase{number: event.Interview, respondentKey: event.RespondentKey}
+		return
+	}
+
+	testCase, ok := report.running[event.Interview]
+	if !ok {
+		return
+	}
+
+	var lastStep *reportStep
+	if len(testCase.steps) > 0 {
+		lastStep = testCase.steps[len(testCase.steps)-1]
+	}
+
+	switch event.Event {
+	case eventQuestionDetected:
+		name := fmt.Sprintf("page %d: %s", len(testCase.steps)+1, event.QuestionType)
+		if len(event.Questions) > 0 {
+			name = fmt.Sprintf("page %d: %s (%s)", len(testCase.steps)+1, strings.Join(event.Questions, ", "), event.QuestionType)
+		}
+		testCase.steps = append(testCase.steps, &reportStep{name: name, started: event.Time})
+	case eventPageFetched:
+		if lastStep != nil && !lastStep.closed {
+			lastStep.duration = event.Time.Sub(lastStep.started)
+			lastStep.failure = event.Error
+			lastStep.closed = true
+		}
+	case eventValidationError:
+		// the answers to the previous page were rejected
+		if lastStep != nil {
+			lastStep.failure = event.Error
+		}
+	case eventInterviewCompleted, eventInterviewAbandoned, eventInterviewErrored:
+		testCase.status = event.Event
+		testCase.failure = event.Error
+		testCase.duration = event.duration
+
+		if lastStep != nil && !lastStep.closed {
+			lastStep.duration = event.Time.Sub(lastStep.started)
+			lastStep.failure = event.Error
+			lastStep.closed = true
+		}
+
+		report.finished = append(report.finished, testCase)
+		delete(report.running, event.Interview)
+	}
+}
+
+func (report *ciReport) write() error {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	cases := append([]*reportCase{}, report.finished...)
+	sort.Slice(cases, func(i, j int) bool { return cases[i].number < cases[j].number })
+
+	file, err := os.Create(report.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if report.format == reportFormatTAP {
+		_, err = file.WriteString(report.tap(cases))
+		return err
+	}
+
+	_, err = file.WriteString(xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report.junit(cases)); err != nil {
+		return err
+	}
+
+	_, err = file.WriteString("\n")
+	return err
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+func formatSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+func (report *ciReport) junit(cases []*reportCase) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      "complete-interviews " + globalConfig.command,
+		Time:      formatSeconds(time.Since(report.started)),
+		Timestamp: report.started.Format("2006-01-02T15:04:05"),
+	}
+
+	for _, testCase := range cases {
+		junitCase := junitTestCase{
+			ClassName: "interviews",
+			Name:      testCase.name(),
+			Time:      formatSeconds(testCase.duration),
+		}
+
+		switch testCase.status {
+		case eventInterviewErrored:
+			junitCase.Failure = &junitFailure{Message: testCase.failure}
+			suite.Failures++
+		case eventInterviewAbandoned:
+			junitCase.Skipped = &junitSkipped{Message: "interview abandoned on purpose"}
+			suite.Skipped++
+		}
+
+		suite.TestCases = append(suite.TestCases, junitCase)
+
+		if !report.steps {
+			continue
+		}
+
+		for _, step := range testCase.steps {
+			stepCase := junitTestCase{
+				ClassName: testCase.name(),
+				Name:      step.name,
+				Time:      formatSeconds(step.duration),
+			}
+
+			if step.failure != "" {
+				stepCase.Failure = &junitFailure{Message: step.failure}
+				suite.Failures++
+			}
+
+			suite.TestCases = append(suite.TestCases, stepCase)
+		}
+	}
+
+	suite.Tests = len(suite.TestCases)
+
+	return suite
+}
+
+func (report *ciReport) tap(cases []*reportCase) string {
+	lines := []string{"TAP version 13", fmt.Sprintf("1..%d", len(cases))}
+
+	writeResult := func(indent string, number int, name string, failure string, duration time.Duration, directive string) {
+		status := "ok"
+		if failure != "" {
+			status = "not ok"
+		}
+
+		line := fmt.Sprintf("%s%s %d - %s", indent, status, number, name)
+		if directive != "" {
+			line += " # " + directive
+		}
+		lines = append(lines, line, indent+"  ---", fmt.Sprintf("%s  duration_ms: %d", indent, duration.Milliseconds()))
+
+		if failure != "" {
+			lines = append(lines, fmt.Sprintf("%s  message: %q", indent, failure))
+		}
+
+		lines = append(lines, indent+"  ...")
+	}
+
+	for i, testCase := range cases {
+		if report.steps && len(testCase.steps) > 0 {
+			lines = append(lines, "    # Subtest: "+testCase.name(), fmt.Sprintf("    1..%d", len(testCase.steps)))
+			for j, step := range testCase.steps {
+				writeResult("    ", j+1, step.name, step.failure, step.duration, "")
+			}
+		}
+
+		directive := ""
+		if testCase.status == eventInterviewAbandoned {
+			directive = "SKIP interview abandoned on purpose"
+		}
+
+		writeResult("", i+1, testCase.name(), testCase.failure, testCase.duration, directive)
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
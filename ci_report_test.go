@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runReportedInterviews(t *testing.T, format string, steps bool) string {
+	globalConfig = &globalConfiguration{command: "complete"}
+
+	dir, err := ioutil.TempDir("", "report")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "report")
+	report := newCIReport(path, format, steps)
+	eventListeners = []eventListener{report.handleEvent}
+	defer func() { eventListeners = nil }()
+
+	pageURL := "http://localhost/interview"
+	body := "<html></html>"
+	fetch := func(tracker *interviewTracker) {
+		tracker.fetch("POST", &pageURL, func() (pageContent, error) {
+			return pageContent{body: &body, url: &pageURL, status: 200}, nil
+		})
+	}
+
+	completed := newInterviewTracker(1, "k1")
+	completed.start()
+	completed.questionDetected(pageInfo{questionType: qTypeNumber, questions: []string{"q10"}})
+	fetch(completed)
+	completed.finish(nil)
+
+	errored := newInterviewTracker(0, "k0")
+	errored.start()
+	errored.questionDetected(pageInfo{questionType: qTypeCategory, questions: []string{"q20"}})
+	fetch(errored)
+	errored.answerRejected(errAnswerRejected)
+	errored.finish(errAnswerRejected)
+
+	abandoned := newInterviewTracker(2, "")
+	abandoned.start()
+	abandoned.abandon()
+	abandoned.finish(nil)
+
+	assert.NoError(t, report.write())
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	return string(content)
+}
+
+func TestJUnitReport(t *testing.T) {
+	assert := assert.New(t)
+
+	content := runReportedInterviews(t, reportFormatJUnit, false)
+
+	suite := junitTestSuite{}
+	assert.NoError(xml.Unmarshal([]byte(content), &suite))
+
+	assert.Equal(3, suite.Tests)
+	assert.Equal(1, suite.Failures)
+	assert.Equal(1, suite.Skipped)
+	assert.Equal("interview 0 (k0)", suite.TestCases[0].Name)
+	assert.Equal(errAnswerRejected.Error(), suite.TestCases[0].Failure.Message)
+	assert.Nil(suite.TestCases[1].Failure)
+	assert.NotNil(suite.TestCases[2].Skipped)
+}
+
+func TestJUnitReportWithSteps(t *testing.T) {
+	assert := assert.New(t)
+
+	content := runReportedInterviews(t, reportFormatJUnit, true)
+
+	suite := junitTestSuite{}
+	assert.NoError(xml.Unmarshal([]byte(content), &suite))
+
+	assert.Equal(5, suite.Tests)
+	assert.Equal(2, suite.Failures)
+	assert.Equal("interview 0 (k0)", suite.TestCases[1].ClassName)
+	assert.Equal("page 1: q20 (Category)", suite.TestCases[1].Name)
+	assert.Equal(errAnswerRejected.Error(), suite.TestCases[1].Failure.Message)
+}
+
+func TestTAPReport(t *testing.T) {
+	assert := assert.New(t)
+
+	content := runReportedInterviews(t, reportFormatTAP, true)
+
+	assert.Contains(content, "TAP version 13\n1..3\n")
+	assert.Contains(content, "    # Subtest: interview 0 (k0)\n    1..1\n    not ok 1 - page 1: q20 (Category)\n")
+	assert.Contains(content, "\nnot ok 1 - interview 0 (k0)\n")
+	assert.Contains(content, "\nok 2 - interview 1 (k1)\n")
+	assert.Contains(content, "\nok 3 - interview 2 # SKIP interview abandoned on purpose\n")
+}
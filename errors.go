@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	errorKindNetwork         = "network"
+	errorKindHTTPStatus      = "http status"
+	errorKindValidation      = "validation rejected"
+	errorKindUnknownQuestion = "unknown question type"
+	errorKindReplayMismatch  = "replay mismatch"
+	errorKindParse           = "parse error"
+	errorKindOther           = "other"
+)
+
+type httpStatusError struct {
+	status     string
+	statusCode int
+	url        string
+	method     string
+	retryAfter time.Duration
+}
+
+func (err *httpStatusError) Error() string {
+	return fmt.Sprintf("http request was unsuccessful: %s (url: %s)", err.status, err.url)
+}
+
+type validationError struct {
+	message string
+}
+
+func (err *validationError) Error() string {
+	return err.message
+}
+
+type unknownQuestionError struct {
+	questions []string
+}
+
+func (err *unknownQuestionError) Error() string {
+	return fmt.Sprintf("unrecognised question type for %s", strings.Join(err.questions, ", "))
+}
+
+type replayMismatchError struct {
+	message string
+}
+
+func (err *replayMismatchError) Error() string {
+	return err.message
+}
+
+type parseError struct {
+	err error
+}
+
+func (err *parseError) Error() string {
+	return fmt.Sprintf("could not parse page: %v", err.err)
+}
+
+func (err *parseError) Unwrap() error {
+	return err.err
+}
+
+func classifyError(err error) string {
+	var statusErr *httpStatusError
+	var validationErr *validationError
+	var unknownQuestionErr *unknownQuestionError
+	var replayErr *replayMismatchError
+	var parseErr *parseError
+	var networkErr *url.Error
+
+	switch {
+	case errors.As(err, &statusErr):
+		return errorKindHTTPStatus
+	case errors.As(err, &validationErr):
+		return errorKindValidation
+	case errors.As(err, &unknownQuestionErr):
+		return errorKindUnknownQuestion
+	case errors.As(err, &replayErr):
+		return errorKindReplayMismatch
+	case errors.As(err, &parseErr):
+		return errorKindParse
+	case errors.As(err, &networkErr):
+		return errorKindNetwork
+	}
+
+	return errorKindOther
+}
+
+type errorGroup struct {
+	kind    string
+	count   int
+	example error
+}
+
+// errorCollector groups all errors of a run by kind, and queues them for
+// the output loop. Unlike a channel it never blocks the workers.
+type errorCollector struct {
+	lock    sync.Mutex
+	groups  []*errorGroup
+	pending []error
+}
+
+func (collector *errorCollector) add(err error, queue bool) {
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	kind := classifyError(err)
+	found := false
+	for _, group := range collector.groups {
+		if group.kind == kind {
+			group.count++
+			found = true
+			break
+		}
+	}
+	if !found {
+		collector.groups = append(collector.groups, &errorGroup{kind: kind, count: 1, example: err})
+	}
+
+	if queue {
+		collector.pending = append(collector.pending, err)
+	}
+}
+
+// takePending returns the errors added since the last call.
+func (collector *errorCollector) takePending() []error {
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	result := collector.pending
+	collector.pending = nil
+
+	return result
+}
+
+func (collector *errorCollector) summary() []errorGroup {
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	result := []errorGroup{}
+	for _, group := range collector.groups {
+		result = append(result, *group)
+	}
+
+	return result
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(errorKindNetwork, classifyError(&url.Error{Op: "Get", URL: "http://localhost", Err: fmt.Errorf("refused")}))
+	assert.Equal(errorKindHTTPStatus, classifyError(&httpStatusError{statusCode: 404}))
+	assert.Equal(errorKindValidation, classifyError(errAnswerRejected))
+	assert.Equal(errorKindUnknownQuestion, classifyError(&unknownQuestionError{questions: []string{"q1"}}))
+	assert.Equal(errorKindReplayMismatch, classifyError(&replayMismatchError{message: "mismatch"}))
+	assert.Equal(errorKindParse, classifyError(&parseError{err: fmt.Errorf("bad")}))
+	assert.Equal(errorKindOther, classifyError(fmt.Errorf("something else")))
+}
+
+func TestErrorCollectorGroupsErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	collector := &errorCollector{}
+	first := &httpStatusError{status: "500 Internal Server Error", statusCode: 500}
+
+	collector.add(first, true)
+	collector.add(&httpStatusError{status: "502 Bad Gateway", statusCode: 502}, true)
+	collector.add(errAnswerRejected, false)
+
+	// never blocks, however many errors there are
+	for i := 0; i < 1000; i++ {
+		collector.add(errAnswerRejected, true)
+	}
+
+	summary := collector.summary()
+	assert.Len(summary, 2)
+	assert.Equal(errorKindHTTPStatus, summary[0].kind)
+	assert.Equal(2, summary[0].count)
+	assert.Equal(first, summary[0].example)
+	assert.Equal(1001, summary[1].count)
+
+	assert.Len(collector.takePending(), 1002)
+	assert.Empty(collector.takePending())
+}
+
+func TestUnknownQuestionType(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `
+<input type="hidden" id="historyOrder" value="3" />
+<div id="segment-q5" class="segment active">
+	<input type="range" id="q5" name="answer-q5" />
+</div>`
+
+	_, _, err := getInterviewResponse(&doc, "")
+	assert.Equal(errorKindUnknownQuestion, classifyError(err))
+	assert.Contains(err.Error(), "q5")
+}
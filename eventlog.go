@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// openEventLog creates a listener that writes every event as a line of
+// JSON to the file at path.
+func openEventLog(path string) (eventListener, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := json.NewEncoder(file)
+	var lock sync.Mutex
+
+	return func(event interviewEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err := encoder.Encode(event); err != nil {
+			printVerbose("log", "Could not write to log file: %v\n", err)
+		}
+	}, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	eventInterviewStarted   = "interview-started"
+	eventPageFetched        = "page-fetched"
+	eventQuestionDetected   = "question-detected"
+	eventAnswersPosted      = "answers-posted"
+	eventValidationError    = "validation-error"
+	eventInterviewCompleted = "interview-completed"
+	eventInterviewAbandoned = "interview-abandoned"
+	eventInterviewErrored   = "interview-errored"
+)
+
+type interviewEvent struct {
+	Time          time.Time  `json:"time"`
+	Event         string     `json:"event"`
+	Interview     int        `json:"interview"`
+	Worker        int        `json:"worker"`
+	RespondentKey string     `json:"respondentKey,omitempty"`
+	Method        string     `json:"method,omitempty"`
+	URL           string     `json:"url,omitempty"`
+	ResponseURL   string     `json:"responseUrl,omitempty"`
+	Status        int        `json:"status,omitempty"`
+	DurationMs    int64      `json:"durationMs,omitempty"`
+	QuestionType  string     `json:"questionType,omitempty"`
+	Questions     []string   `json:"questions,omitempty"`
+	Answers       url.Values `json:"answers,omitempty"`
+	Error         string     `json:"error,omitempty"`
+
+	duration time.Duration
+	body     *string
+}
+
+// eventListener receives the events of all interviews; listeners are called
+// from the worker goroutines and must be safe for concurrent use.
+type eventListener func(event interviewEvent)
+
+var eventListeners []eventListener
+
+func addEventListener(listener eventListener) {
+	eventListeners = append(eventListeners, listener)
+}
+
+// runFinishedListeners are called once when all interviews are done or
+// the run is interrupted, e.g. to write reports.
+var runFinishedListeners []func()
+var runFinished sync.Once
+
+func addRunFinishedListener(listener func()) {
+	runFinishedListeners = append(runFinishedListeners, listener)
+}
+
+func finishRun() {
+	runFinished.Do(func() {
+		for _, listener := range runFinishedListeners {
+			listener()
+		}
+	})
+}
+
+type interviewTracker struct {
+	number        int
+	respondentKey string
+	worker        int
+	started       time.Time
+	abandoned     bool
+}
+
+func newInterviewTracker(number int, respondentKey string) *interviewTracker {
+	return &interviewTracker{number: number, respondentKey: respondentKey, started: time.Now()}
+}
+
+func (tracker *interviewTracker) emit(event interviewEvent) {
+	if len(eventListeners) == 0 {
+		return
+	}
+
+	event.Time = time.Now()
+	event.Interview = tracker.number
+	event.Worker = tracker.worker
+	event.RespondentKey = tracker.respondentKey
+	event.DurationMs = event.duration.Milliseconds()
+
+	for _, listener := range eventListeners {
+		listener(event)
+	}
+}
+
+func (tracker *interviewTracker) start() {
+	tracker.emit(interviewEvent{Event: eventInterviewStarted})
+}
+
+// fetch performs a request and reports the resulting page (or error).
+func (tracker *interviewTracker) fetch(method string, url *string, request func() (pageContent, error)) (pageContent, error) {
+	started := time.Now()
+	result, err := request()
+
+	event := interviewEvent{Event: eventPageFetched, Method: method, URL: *url, duration: time.Since(started)}
+
+	if err != nil {
+		event.Error = err.Error()
+
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) {
+			event.Status = statusErr.statusCode
+		}
+	} else {
+		event.ResponseURL = *result.url
+		event.Status = result.status
+		event.body = result.body
+	}
+
+	tracker.emit(event)
+
+	return result, err
+}
+
+func (tracker *interviewTracker) get(client http.Client, url *string) (pageContent, error) {
+	return tracker.fetch("GET", url, func() (pageContent, error) {
+		return getContent(client, url)
+	})
+}
+
+func (tracker *interviewTracker) post(client http.Client, url *string, body url.Values) (pageContent, error) {
+	tracker.emit(interviewEvent{Event: eventAnswersPosted, URL: *url, Answers: body})
+
+	return tracker.fetch("POST", url, func() (pageContent, error) {
+		return postContent(client, url, body)
+	})
+}
+
+func (tracker *interviewTracker) questionDetected(page pageInfo) {
+	tracker.emit(interviewEvent{Event: eventQuestionDetected, QuestionType: page.questionType, Questions: page.questions})
+}
+
+func (tracker *interviewTracker) answerRejected(err error) {
+	tracker.emit(interviewEvent{Event: eventValidationError, Error: err.Error()})
+}
+
+func (tracker *interviewTracker) abandon() {
+	tracker.abandoned = true
+}
+
+func (tracker *interviewTracker) finish(err error) {
+	event := interviewEvent{Event: eventInterviewCompleted, duration: time.Since(tracker.started)}
+
+	if err != nil {
+		event.Event = eventInterviewErrored
+		event.Error = err.Error()
+	} else if tracker.abandoned {
+		event.Event = eventInterviewAbandoned
+	}
+
+	tracker.emit(event)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectEvents(t *testing.T) *[]interviewEvent {
+	events := []interviewEvent{}
+	eventListeners = []eventListener{func(event interviewEvent) {
+		events = append(events, event)
+	}}
+	t.Cleanup(func() { eventListeners = nil })
+
+	return &events
+}
+
+func TestInterviewEvents(t *testing.T) {
+	numberOfRequests := 0
+	setupMocking(t, "pages/test-interview", &numberOfRequests)
+	events := collectEvents(t)
+
+	assert := assert.New(t)
+
+	tracker := newInterviewTracker(3, "key3")
+	tracker.start()
+	err := performInterview(http.Client{}, &completeConfig.interviewURL, tracker)
+	tracker.finish(err)
+	assert.NoError(err)
+
+	counts := map[string]int{}
+	for _, event := range *events {
+		counts[event.Event]++
+
+		assert.Equal(3, event.Interview)
+		assert.Equal("key3", event.RespondentKey)
+	}
+
+	assert.Equal(1, counts[eventInterviewStarted])
+	assert.Equal(13, counts[eventPageFetched])
+	assert.Equal(12, counts[eventQuestionDetected])
+	assert.Equal(12, counts[eventAnswersPosted])
+	assert.Equal(1, counts[eventInterviewCompleted])
+
+	assert.Equal(eventInterviewStarted, (*events)[0].Event)
+	assert.Equal(eventInterviewCompleted, (*events)[len(*events)-1].Event)
+}
+
+func TestEventLogWritesJSONLines(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	dir, err := ioutil.TempDir("", "events")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.log")
+	listener, err := openEventLog(path)
+	assert.NoError(err)
+
+	eventListeners = []eventListener{listener}
+	defer func() { eventListeners = nil }()
+
+	tracker := newInterviewTracker(7, "abc")
+	tracker.start()
+	tracker.answerRejected(errAnswerRejected)
+
+	file, err := os.Open(path)
+	assert.NoError(err)
+	defer file.Close()
+
+	lines := []map[string]interface{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := map[string]interface{}{}
+		assert.NoError(json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+
+	assert.Len(lines, 2)
+	assert.Equal(eventInterviewStarted, lines[0]["event"])
+	assert.Equal(float64(7), lines[0]["interview"])
+	assert.Equal("abc", lines[0]["respondentKey"])
+	assert.Equal(eventValidationError, lines[1]["event"])
+	assert.Equal(errAnswerRejected.Error(), lines[1]["error"])
+}
@@ -0,0 +1,64 @@
+// Command generate-fixtures writes the pages of an ODIN script as Nfield
+// would serve them, one page<n>.html per page or question, like the
+// captured interviews in pages/:
+//
+//	go run ./fixtures/cmd/generate-fixtures --template chicago --out pages/generated test-script.odin
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"complete-interviews/fixtures"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	templateFlag = kingpin.Flag("template", "Template of the pages until the script sets one with *TEMPLATE").Default("default").Enum(fixtures.Templates...)
+	outFlag      = kingpin.Flag("out", "Folder to write the pages to").Required().String()
+	scriptArg    = kingpin.Arg("script", "The ODIN script").Required().ExistingFile()
+)
+
+func main() {
+	kingpin.Parse()
+
+	if err := generateFixtures(*scriptArg, *templateFlag, *outFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func generateFixtures(scriptPath string, template string, dir string) error {
+	script, err := ioutil.ReadFile(scriptPath)
+	if err != nil {
+		return err
+	}
+
+	questions, err := fixtures.ParseScript(string(script), template)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, question := range questions {
+		page, err := fixtures.Render(question)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("page%d.html", i+1))
+		if err := ioutil.WriteFile(path, []byte(page), 0644); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: %s (%s)\n", path, question.ID, question.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package fixtures turns ODIN question definitions into pages as Nfield
+// would serve them with the default or chicago template, so tests can
+// cover variants without capturing pages from a real interview:
+//
+//	questions, err := fixtures.ParseScript(`*QUESTION 10 *CODES 61L1 *MULTI *MIN 2
+//	Pick two
+//
+//	1:One
+//	2:Two
+//	3:Three`, "chicago")
+//	page, err := fixtures.Render(questions[0])
+//
+// Only the parts the interview parser reads are generated: the segment,
+// the category list with its data attributes, the answer-qN inputs and
+// the navigation. Run cmd/generate-fixtures to write the pages of a whole
+// script to a folder.
+package fixtures
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The question types, as the interview parser names them.
+const (
+	TypeOpenMulti  = "OpenMulti"
+	TypeOpenSingle = "OpenSingle"
+	TypeNumber     = "Number"
+	TypeCategory   = "Category"
+	TypePage       = "Page"
+)
+
+// Templates are the Nfield templates pages can be rendered in.
+var Templates = []string{"default", "chicago"}
+
+// Question is a *PAGE or *QUESTION of an ODIN script.
+type Question struct {
+	ID       string
+	Type     string
+	Template string
+
+	text        []string
+	categories  []category
+	multi       bool
+	minimum     string
+	maximum     string
+	length      int
+	instruction string
+	columns     string
+}
+
+type category struct {
+	Code  int
+	Label string
+}
+
+var (
+	tokenRegexp    = regexp.MustCompile(`\*\w+|"[^"]*"|\[[^\]]*\]|\S+`)
+	positionRegexp = regexp.MustCompile(`^\d+L(\d+)$`)
+	categoryRegexp = regexp.MustCompile(`^(\d+):(.*)$`)
+)
+
+var scriptTemplates = map[string]string{
+	"NfieldChicago": "chicago",
+	"NfieldDefault": "default",
+}
+
+// ParseScript splits a script in its pages and questions. A *TEMPLATE
+// line changes the template of the questions after it.
+func ParseScript(script string, template string) ([]Question, error) {
+	questions := []Question{}
+	pages := 0
+
+	for _, line := range strings.Split(strings.Replace(script, "\r\n", "\n", -1), "\n") {
+		tokens := tokenRegexp.FindAllString(strings.TrimSpace(line), -1)
+
+		switch {
+		case len(tokens) > 0 && strings.EqualFold(tokens[0], "*TEMPLATE"):
+			if len(tokens) < 2 || scriptTemplates[strings.Trim(tokens[1], `"`)] == "" {
+				return nil, fmt.Errorf("unknown template in '%s'", line)
+			}
+			template = scriptTemplates[strings.Trim(tokens[1], `"`)]
+		case len(tokens) > 0 && strings.EqualFold(tokens[0], "*PAGE"):
+			pages++
+			questions = append(questions, Question{
+				ID:       fmt.Sprintf("p%d", pages),
+				Type:     TypePage,
+				Template: template,
+			})
+		case len(tokens) > 0 && strings.EqualFold(tokens[0], "*QUESTION"):
+			question, err := parseQuestionLine(tokens)
+			if err != nil {
+				return nil, err
+			}
+			question.Template = template
+			questions = append(questions, question)
+		case len(questions) > 0:
+			if err := addText(&questions[len(questions)-1], strings.TrimSpace(line)); err != nil {
+				return nil, err
+			}
+		case strings.TrimSpace(line) != "":
+			return nil, fmt.Errorf("text outside of a question: '%s'", line)
+		}
+	}
+
+	return questions, nil
+}
+
+func parseQuestionLine(tokens []string) (Question, error) {
+	if len(tokens) < 3 {
+		return Question{}, fmt.Errorf("incomplete question '%s'", strings.Join(tokens, " "))
+	}
+
+	question := Question{ID: "q" + tokens[1]}
+
+	for i := 2; i < len(tokens); i++ {
+		keyword := strings.ToUpper(tokens[i])
+		value := ""
+		if i+1 < len(tokens) && !strings.HasPrefix(tokens[i+1], "*") {
+			value = strings.Trim(tokens[i+1], `"[]`)
+		}
+
+		switch keyword {
+		case "*CODES", "*ALPHA", "*OPEN", "*NUMBER":
+			question.Type = map[string]string{
+				"*CODES":  TypeCategory,
+				"*ALPHA":  TypeOpenSingle,
+				"*OPEN":   TypeOpenMulti,
+				"*NUMBER": TypeNumber,
+			}[keyword]
+
+			if matched := positionRegexp.FindStringSubmatch(value); len(matched) > 0 {
+				question.length, _ = strconv.Atoi(matched[1])
+			}
+		case "*MULTI":
+			question.multi = true
+		case "*MIN":
+			question.minimum = value
+		case "*MAX":
+			question.maximum = value
+		case "*UIOPTIONS":
+			for _, option := range strings.Split(value, ";") {
+				splitOption := strings.SplitN(option, "=", 2)
+				if len(splitOption) < 2 {
+					continue
+				}
+				if splitOption[0] == "instruction" {
+					question.instruction = splitOption[1]
+				} else if splitOption[0] == "columns" {
+					question.columns = splitOption[1]
+				}
+			}
+		default:
+			if strings.HasPrefix(keyword, "*") && question.Type == "" {
+				return Question{}, fmt.Errorf("question type %s of %s is not supported", keyword, question.ID)
+			}
+		}
+	}
+
+	if question.Type == "" {
+		return Question{}, fmt.Errorf("question %s has no type", question.ID)
+	}
+
+	if question.Type == TypeNumber {
+		if question.minimum == "" {
+			question.minimum = "0"
+		}
+		if question.maximum == "" {
+			question.maximum = strconv.Itoa(pow10(question.length) - 1)
+		}
+	}
+
+	return question, nil
+}
+
+func addText(question *Question, line string) error {
+	if line == "" {
+		return nil
+	}
+
+	matched := categoryRegexp.FindStringSubmatch(line)
+	if question.Type == TypeCategory && len(matched) > 0 {
+		code, err := strconv.Atoi(matched[1])
+		if err != nil {
+			return err
+		}
+
+		question.categories = append(question.categories, category{Code: code, Label: strings.TrimSpace(matched[2])})
+		return nil
+	}
+
+	question.text = append(question.text, line)
+	return nil
+}
+
+func pow10(exponent int) int {
+	result := 1
+	for i := 0; i < exponent; i++ {
+		result *= 10
+	}
+
+	return result
+}
+
+// Render returns the page of a question in its template.
+func Render(question Question) (string, error) {
+	pageTemplate, ok := pageTemplates[question.Template]
+	if !ok {
+		return "", fmt.Errorf("unknown template '%s'", question.Template)
+	}
+
+	// Nfield always sends the limits of a multi coded question
+	if question.multi && question.minimum == "" {
+		question.minimum = "1"
+	}
+	if question.multi && question.maximum == "" {
+		question.maximum = strconv.Itoa(len(question.categories))
+	}
+
+	buf := new(bytes.Buffer)
+	err := pageTemplate.Execute(buf, map[string]interface{}{
+		"ID":          question.ID,
+		"Type":        question.Type,
+		"Text":        question.text,
+		"Categories":  question.categories,
+		"Multi":       question.multi,
+		"Minimum":     question.minimum,
+		"Maximum":     question.maximum,
+		"Length":      question.length,
+		"Instruction": question.instruction,
+		"Columns":     question.columns,
+		"ScreenID":    "032794ea-dfbb-4c33-95c2-2fbe5befd885",
+		"SurveyID":    "ed486ffc-62bc-4c01-a2e1-7fac7fd4a5f1",
+	})
+
+	return buf.String(), err
+}
@@ -0,0 +1,46 @@
+package fixtures
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScript(t *testing.T) {
+	assert := assert.New(t)
+
+	questions, err := ParseScript("*PAGE\nWelcome\n\n*TEMPLATE \"NfieldChicago\"\n*QUESTION 10 *CODES 61L1 *MULTI\nPick any\n\n1:One\n2:Two", "default")
+	assert.NoError(err)
+	assert.Len(questions, 2)
+
+	assert.Equal("p1", questions[0].ID)
+	assert.Equal(TypePage, questions[0].Type)
+	assert.Equal("default", questions[0].Template)
+
+	assert.Equal("q10", questions[1].ID)
+	assert.Equal(TypeCategory, questions[1].Type)
+	assert.Equal("chicago", questions[1].Template)
+
+	page, err := Render(questions[1])
+	assert.NoError(err)
+	assert.True(strings.Contains(page, `name="answer-q10-2"`))
+	assert.True(strings.Contains(page, `data-minimum="1" data-maximum="2"`))
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := ParseScript("*QUESTION 1 *GRID\nA grid", "default")
+	assert.Error(err)
+
+	_, err = ParseScript("*TEMPLATE \"Unknown\"", "default")
+	assert.Error(err)
+
+	_, err = ParseScript("Some text", "default")
+	assert.Error(err)
+
+	question := Question{ID: "q1", Type: TypePage, Template: "unknown"}
+	_, err = Render(question)
+	assert.Error(err)
+}
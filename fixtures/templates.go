@@ -0,0 +1,171 @@
+package fixtures
+
+import "html/template"
+
+// pageTemplates are the parts of the Nfield templates the parser looks at,
+// taken from pages captured in real interviews.
+var pageTemplates = map[string]*template.Template{
+	"default": template.Must(template.New("default").Parse(`<!DOCTYPE html>
+<html dir="LTR">
+    <head>
+        <meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+        <title>Nfield Web Interviewing Demo</title>
+    </head>
+    <body class="niposoftware" data-startid="">
+<div id="interview-screen">
+  <div class="previous-segments">
+  </div>
+  <form method="post" action="">
+    <input id="screenId" name="screenId" type="hidden" value="{{.ScreenID}}">
+    <input id="historyOrder" name="historyOrder" type="hidden" value="0">
+    <div id="segment-{{.ID}}" class="segment active " data-surveyid="{{.SurveyID}}" data-interviewid=""{{if .Instruction}} data-instruction="{{.Instruction}}"{{end}}{{if .Columns}} data-columns="{{.Columns}}"{{end}} data-bind="visible: isVisible($element)">
+<div class="validation-message">
+    <span class="message">
+    </span>
+</div>                <div class="group text{{if ne .Type "Page"}} question{{end}}">
+{{- range .Text}}
+<p>
+        <span class="style-0">{{.}}<br /></span>
+</p>
+{{- end}}
+                </div>
+{{- if eq .Type "Category"}}
+                <div class="group categorylist">
+<div id="categorylist-{{.ID}}" class="categorylist categories required "{{if .Multi}} data-minimum="{{.Minimum}}" data-maximum="{{.Maximum}}"{{end}}>
+            <input type="hidden" class="answerOrder" name="answer-{{.ID}}-m" id="categorylist-{{.ID}}-multi" value="" />
+<div class="categorygroup" data-bind="visible: isVisible($element)">
+{{- range .Categories}}
+    <div id="category-{{$.ID}}-{{.Code}}" class="category {{if $.Multi}}multi{{else}}single{{end}}" data-bind="visible: isVisible($element)">
+<div class="category-padding">
+<div class="category-input">
+    <input id="{{$.ID}}-{{.Code}}" class="category" name="answer-{{$.ID}}{{if $.Multi}}-{{.Code}}{{end}}" value="{{$.ID}}-{{.Code}}" type="{{if $.Multi}}checkbox{{else}}radio{{end}}" />
+</div>
+<div class="category-label">
+        <span class="style-0">{{.Label}}</span>
+</div>
+</div>
+    </div>
+{{- end}}
+</div></div>
+                </div>
+{{- else if ne .Type "Page"}}
+                <div class="group open-group">
+<div class="container open" data-bind="visible: isVisible($element)">
+{{- if eq .Type "OpenSingle"}}
+<input id="{{.ID}}" type="text" class="open alpha required" value="" name="answer-{{.ID}}"{{if .Length}} maxlength="{{.Length}}"{{end}} />
+{{- else if eq .Type "Number"}}
+<input id="{{.ID}}" type="text" class="open number required" value="" name="answer-{{.ID}}" data-fraction-length="0" data-number-of-decimals="2" data-minimum="{{.Minimum}}" data-maximum="{{.Maximum}}" data-range="" />
+{{- else}}
+<textarea id="{{.ID}}" class="open required" name="answer-{{.ID}}"></textarea>
+{{- end}}
+</div>                </div>
+{{- end}}
+    </div>
+    <div id="segment-end">
+<div id="navigation-container">
+{{- if ne .Type "Page"}}
+    <input type="submit" name="button-back" value="Back" class="button button-back" />
+{{- end}}
+    <input type="submit" name="button-next" value="Next" class="button button-next" />
+{{- if ne .Type "Page"}}
+    <input type="submit" name="button-clear" value="Clear" class="button button-clear" />
+{{- end}}
+</div>
+    </div>
+  </form>
+</div>
+    </body>
+</html>
+`)),
+	"chicago": template.Must(template.New("chicago").Parse(`<!doctype html>
+<html class="no-js" lang="">
+	<head>
+		<meta charset="utf-8">
+		<title>Template</title>
+	</head>
+	<body class="niposoftware LTR" data-startid="">
+<div id="interview-screen">
+  <div class="previous-segments">
+  </div>
+  <form method="post" action="">
+    <input id="screenId" name="screenId" type="hidden" value="{{.ScreenID}}">
+    <input id="historyOrder" name="historyOrder" type="hidden" value="0">
+                                <div class="card" id="activeCard">
+		<div  id="segment-{{.ID}}" class="segment active " data-surveyid="{{.SurveyID}}" data-interviewid=""{{if .Instruction}} data-instruction="{{.Instruction}}"{{end}}{{if .Columns}} data-columns="{{.Columns}}"{{end}} data-bind="visible: isVisible($element)">
+{{- if eq .Type "Page"}}
+<div class="validation-message">
+    <span class="message">
+    </span>
+</div>
+{{- range .Text}}<p>
+        <span class="style-0">{{.}}<br /></span>
+</p>
+{{- end}}
+{{- else}}
+					<h2>
+{{- range .Text}}
+        <span class="style-0">{{.}}<br /></span>
+{{- end}}
+					</h2>
+{{- if .Instruction}}
+					<p>{{.Instruction}}</p>
+{{- end}}
+<div class="validation-message">
+    <span class="message">
+    </span>
+</div>
+{{- end}}
+{{- if eq .Type "Category"}}
+<span class="questionType" data-type="default"></span>
+<div id="categorylist-{{.ID}}" class="categorylist categories required "{{if .Multi}} data-minimum="{{.Minimum}}" data-maximum="{{.Maximum}}"{{end}}>
+            <input type="hidden" class="answerOrder" name="answer-{{.ID}}-m" id="categorylist-{{.ID}}-multi" value="" />
+<ul class="answers cols-{{if .Columns}}{{.Columns}}{{else}}1{{end}} categorygroup" data-bind="visible: isVisible($element)">
+{{- range .Categories}}
+    <li class="category {{if $.Multi}}multi{{else}}single{{end}}" data-bind="visible: isVisible($element)">
+<div class="toggle scale">
+            <div>
+                <span class="input"></span>
+                <input style="display: none;" class="category " type="{{if $.Multi}}checkbox{{else}}radio{{end}}" name="answer-{{$.ID}}{{if $.Multi}}-{{.Code}}{{end}}" value="{{$.ID}}-{{.Code}}" id="{{$.ID}}-{{.Code}}" />
+        <span class="style-0">{{.Label}}</span>
+            </div>
+</div>
+    </li>
+{{- end}}
+</ul>
+</div>
+{{- else if ne .Type "Page"}}
+<span class="questionType" data-type="form"></span>
+<div class="answerCategory" data-bind="visible: isVisible($element)">
+{{- if eq .Type "OpenSingle"}}
+<span class="questionType" data-type="alphanumeric"></span>
+<div class="col-sm-12">
+<input id="{{.ID}}" type="text" class="open text form-control required autosize " value="" placeholder="" name="answer-{{.ID}}"{{if .Length}} maxlength="{{.Length}}"{{end}} />
+</div>
+{{- else if eq .Type "Number"}}
+<span class="questionType" data-type="alphanumeric"></span>
+<div class="col-sm-12">
+<input id="{{.ID}}" type="number" class="open number form-control required  " value="" placeholder="" name="answer-{{.ID}}" step="1" data-fraction-length="0" data-number-of-decimals="2" data-minimum="{{.Minimum}}" data-maximum="{{.Maximum}}" data-range="" />
+</div>
+{{- else}}
+<span class="questionType" data-type="text"></span>
+		<textarea id="{{.ID}}" class="form-control open required" name="answer-{{.ID}}"></textarea>
+{{- end}}
+</div>
+{{- end}}
+	</div>
+<div id="navigation-container">
+    <div class="pagination">
+		<input type="submit" name="button-back" value="Back" class="btn btn-prev button-back" />
+		<input type="submit" name="button-next" value="Next" class="btn btn-primary button-next" />
+{{- if ne .Type "Page"}}
+		<input type="submit" name="button-clear" value="Clear" class="btn btn-default button-clear" />
+{{- end}}
+    </div>
+</div>
+                                </div>
+  </form>
+</div>
+	</body>
+</html>
+`)),
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"math/rand"
-	"net/url"
+	"net/http"
 	"os"
 	"time"
 
@@ -13,25 +13,87 @@ import (
 var (
 	requestTimeoutFlag = kingpin.Flag("request-timeout", "Timeout on requests").Default("30s").Duration()
 	verboseOutputFlag  = kingpin.Flag("verbose", "Enable verbose output for debugging purposes").Short('v').Default("false").Bool()
+	outputModeFlag     = kingpin.Flag("output", "Progress output: tty, plain, json or quiet (default: tty on a terminal, plain otherwise)").Default("auto").Enum("auto", "tty", "plain", "json", "quiet")
+	progressEveryFlag  = kingpin.Flag("progress-interval", "Time between progress lines for plain and json output").Default("10s").Duration()
+	maxRetriesFlag     = kingpin.Flag("retries", "Number of retries after a network error or 5xx/429 response").Default("3").Int()
+	retryWaitFlag      = kingpin.Flag("retry-wait", "Wait time before the first retry (doubles on every attempt)").Default("1s").Duration()
+	maxRetryWaitFlag   = kingpin.Flag("max-retry-wait", "Maximum wait time between retries").Default("30s").Duration()
+	caCertFlag         = kingpin.Flag("ca-cert", "PEM file with additional trusted CA certificates").Default("").String()
+	insecureFlag       = kingpin.Flag("insecure", "Do not verify TLS certificates").Default("false").Bool()
+	clientCertFlag     = kingpin.Flag("client-cert", "PEM file with a client certificate").Default("").String()
+	clientKeyFlag      = kingpin.Flag("client-key", "PEM file with the key for the client certificate").Default("").String()
+	proxyFlag          = kingpin.Flag("proxy", "Outbound HTTP proxy (default: HTTP_PROXY/HTTPS_PROXY)").Default("").String()
+	headerFlag         = kingpin.Flag("header", "Extra header for requests to the interview host, e.g. 'X-Test: 1' (can be repeated)").Short('H').Strings()
+	basicAuthFlag      = kingpin.Flag("basic-auth", "Basic authentication for the interview host as 'user:password'").Default("").String()
+	bearerTokenFlag    = kingpin.Flag("bearer-token", "Bearer token for the Authorization header of requests to the interview host").Default("").String()
+	logFileFlag        = kingpin.Flag("log-file", "Write a JSON line for every interview event to this file").Default("").String()
+	artefactsDirFlag   = kingpin.Flag("artefacts-dir", "Save the last pages and requests of every failed interview to this folder").Default("").String()
+	artefactsPagesFlag = kingpin.Flag("artefacts-pages", "Number of pages to save for every failed interview").Default("5").Int()
+	reportFileFlag     = kingpin.Flag("report-file", "Write a test report with one test case per interview to this file").Default("").String()
+	reportFormatFlag   = kingpin.Flag("report-format", "Format of the test report (junit or tap)").Default("junit").Enum("junit", "tap")
+	reportStepsFlag    = kingpin.Flag("report-steps", "Add every question page to the test report as a separate step").Default("false").Bool()
+	metricsAddrFlag    = kingpin.Flag("metrics-addr", "Serve Prometheus metrics on this address (e.g. :9100) while running").Default("").String()
+	answersReportFlag  = kingpin.Flag("answers-report", "Write the answer distributions and run statistics to this HTML (or .md) file").Default("").String()
+	responsesCSVFlag   = kingpin.Flag("responses-csv", "Write the posted answers to this CSV file, one row per interview").Default("").String()
+	userAgentFlag      = kingpin.Flag("user-agent", "User agent; when repeated each worker picks one at random").Strings()
 
 	completeCommand                 = kingpin.Command("complete", "Complete interviews based on a link").Default()
 	completeMaxConcurrencyFlag      = completeCommand.Flag("concurrency", "Maximum number of concurrent interviews").Short('c').Default("10").Int()
 	completeWaitBetweenPostsFlag    = completeCommand.Flag("wait-time", "Wait time between answering questions").Default("0").Duration()
-	completeRespondentKeyFormatFlag = completeCommand.Flag("respondent-key", "Format for respondent key").Default("").String()
-	completeTargetArg               = completeCommand.Arg("count", "The number of completes to generate.").Required().Int()
+	completeRespondentKeyFormatFlag = completeCommand.Flag("respondent-key", "Template for the respondent key, e.g. 'team-a-{n+1000:6}' or 'x{run}-{rand:6}'").Default("").String()
+	completeRunIDFlag               = completeCommand.Flag("run-id", "Run id used by {run}, {rand}, {uuid} and {hash} in the respondent key (random if not set)").Default("").String()
+	completeSampleFileFlag          = completeCommand.Flag("sample", "CSV file with the respondent key and url parameters for each interview").Default("").String()
+	completeSampleKeyColumnFlag     = completeCommand.Flag("sample-key-column", "Column in the sample file that contains the respondent key").Default("respondentkey").String()
+	completeStateFileFlag           = completeCommand.Flag("state-file", "File to keep track of finished interviews, so an interrupted run can be resumed").Default("").String()
+	completeErroredOnlyFlag         = completeCommand.Flag("errored-only", "Only rerun interviews that errored according to the state file").Default("false").Bool()
+	completeReplayDirFlag           = completeCommand.Flag("replay-dir", "Write the answers posted in every interview that was not abandoned to a replay file in this folder").Default("").String()
+	completeBreakoffFlag            = completeCommand.Flag("breakoff", "Percentage of interviews to abandon before completing them").Default("0").Int()
+	completeBreakoffQuestionsFlag   = completeCommand.Flag("breakoff-question", "Question (e.g. q30) at which to abandon interviews; random if not set").Strings()
+	completeBackFlag                = completeCommand.Flag("back", "Chance (percentage) of pressing the back button on a page (at most once per page)").Default("0").Int()
+	completeClearFlag               = completeCommand.Flag("clear", "Chance (percentage) of pressing the clear button on a page (at most once per page)").Default("0").Int()
+	completeTargetArg               = completeCommand.Arg("count", "The number of completes to generate (0 for one per row in the sample file).").Required().Int()
 	completeInterviewURLArg         = completeCommand.Arg("url", "The url to the interview to complete.").Required().String()
 
-	recordCommand         = kingpin.Command("record", "Record an interview for later playback")
-	recordOutputFileFlag  = recordCommand.Flag("replay-file", "Output file to write the recording to").Short('r').Default("interview.replay").String()
-	recordTargetArg       = recordCommand.Arg("count", "The number of completes to record.").Required().Int()
-	recordInterviewURLArg = recordCommand.Arg("url", "The url to the interview to complete.").Required().String()
-
-	replayCommand              = kingpin.Command("replay", "Replay interviews based on a replay file")
-	replayMaxConcurrencyFlag   = replayCommand.Flag("concurrency", "Maximum number of concurrent interviews").Short('c').Default("10").Int()
-	replayWaitBetweenPostsFlag = replayCommand.Flag("wait-time", "Wait time between answering questions").Default("0").Duration()
-	replayTargetArg            = replayCommand.Arg("count", "The number of replays to generate.").Required().Int()
-	replayInterviewURLArg      = replayCommand.Arg("url", "The url to the interview to complete.").Required().String()
-	replayFileArg              = replayCommand.Arg("replay-file", "Replay file to determine responses").Default("interview.replay").String()
+	recordCommand           = kingpin.Command("record", "Record an interview for later playback")
+	recordOutputFileFlag    = recordCommand.Flag("replay-file", "Output file to write the recording to").Short('r').Default("interview.replay").String()
+	recordListenFlag        = recordCommand.Flag("listen", "Address for the recording proxy to listen on (port 0 picks a free port)").Default(":4222").String()
+	recordNoBrowserFlag     = recordCommand.Flag("no-browser", "Do not open the recording proxy in a browser").Default("false").Bool()
+	recordExternalHostsFlag = recordCommand.Flag("external-host", "Host that the interview may redirect to directly instead of through the proxy (repeatable)").Strings()
+	recordNoOverlayFlag     = recordCommand.Flag("no-overlay", "Do not add the overlay to tag questions for replay to recorded pages").Default("false").Bool()
+	recordHARFileFlag       = recordCommand.Flag("har-file", "Also write every proxied request and response, with timings, to this HAR file (bodies are cut off after 256 KiB)").Default("").String()
+	recordTargetArg         = recordCommand.Arg("count", "The number of completes to record.").Required().Int()
+	recordInterviewURLArg   = recordCommand.Arg("url", "The url to the interview to complete.").Required().String()
+
+	replayCommand              = kingpin.Command("replay", "Replay interviews based on a replay file, or inspect and edit replay files")
+	replayRunCommand           = replayCommand.Command("run", "Replay interviews based on a replay file (default)").Default()
+	replayMaxConcurrencyFlag   = replayRunCommand.Flag("concurrency", "Maximum number of concurrent interviews").Short('c').Default("10").Int()
+	replayWaitBetweenPostsFlag = replayRunCommand.Flag("wait-time", "Wait time between answering questions").Default("0").Duration()
+	replayStateFileFlag        = replayRunCommand.Flag("state-file", "File to keep track of finished replays, so an interrupted run can be resumed").Default("").String()
+	replayErroredOnlyFlag      = replayRunCommand.Flag("errored-only", "Only rerun replays that errored according to the state file").Default("false").Bool()
+	replaySampleFileFlag       = replayRunCommand.Flag("sample", "CSV file with the respondent key, url parameters and tagged answers for each replay; the key and parameters are added to the start url").Default("").String()
+	replaySampleKeyColumnFlag  = replayRunCommand.Flag("sample-key-column", "Column in the sample file that contains the respondent key").Default("respondentkey").String()
+	replayThinkTimeFlag        = replayRunCommand.Flag("think-time", "Wait the recorded think time before answering each page").Default("false").Bool()
+	replayTargetArg            = replayRunCommand.Arg("count", "The number of replays to generate.").Required().Int()
+	replayInterviewURLArg      = replayRunCommand.Arg("url", "The url to the interview to complete.").Required().String()
+	replayFileArg              = replayRunCommand.Arg("replay-file", "Replay file to determine responses").Default("interview.replay").String()
+
+	replayListCommand     = replayCommand.Command("list", "Print the steps and questions of a replay file")
+	replayListFileArg     = replayListCommand.Arg("replay-file", "Replay file to print").Default("interview.replay").String()
+	replayDiffCommand     = replayCommand.Command("diff", "Compare the answers of two replay files by question")
+	replayDiffFileArg     = replayDiffCommand.Arg("replay-file", "Replay file to compare").Required().String()
+	replayDiffOtherArg    = replayDiffCommand.Arg("other-replay-file", "Replay file to compare with").Required().String()
+	replayMergeCommand    = replayCommand.Command("merge", "Concatenate replay files into one, to replay them in order")
+	replayMergeOutputArg  = replayMergeCommand.Arg("output-file", "Replay file to write").Required().String()
+	replayMergeInputsArg  = replayMergeCommand.Arg("replay-files", "Replay files to concatenate").Required().Strings()
+	replayDropCommand     = replayCommand.Command("drop", "Remove a step from a replay file")
+	replayDropFileArg     = replayDropCommand.Arg("replay-file", "Replay file to edit").Required().String()
+	replayDropStepArg     = replayDropCommand.Arg("step", "Number of the step to remove (see replay list)").Required().Int()
+	replayReplaceCommand  = replayCommand.Command("replace", "Replace the answers of a step in a replay file")
+	replayReplaceFileArg  = replayReplaceCommand.Arg("replay-file", "Replay file to edit").Required().String()
+	replayReplaceStepArg  = replayReplaceCommand.Arg("step", "Number of the step to replace (see replay list)").Required().Int()
+	replayReplaceValueArg = replayReplaceCommand.Arg("answers", "New answers, e.g. 'answer-q1=q1-2' (a key can be repeated)").Required().Strings()
+	replayUpgradeCommand  = replayCommand.Command("upgrade", "Rewrite replay files in the current format")
+	replayUpgradeFilesArg = replayUpgradeCommand.Arg("replay-files", "Replay files to upgrade").Required().Strings()
 )
 
 /* GLOBAL DATA STRUCTS */
@@ -39,32 +101,67 @@ type completeStatus struct {
 	completed int
 	errored   int
 	active    int
+	retried   int64
+	abandoned int64
+	workers   int64
+
+	// failures to write output files, which fail the run even when all
+	// interviews succeeded
+	outputErrors int64
 
 	lastLinesWritten int
-	replaySteps      *[]url.Values
+	replaySteps      *[]replayStep
 }
 
 type globalConfiguration struct {
 	verboseOutput  bool
 	requestTimeout time.Duration
 	command        string
+
+	outputMode       string
+	progressInterval time.Duration
+
+	maxRetries   int
+	retryWait    time.Duration
+	maxRetryWait time.Duration
+
+	transport    http.RoundTripper
+	artefactsDir string
 }
 
 type completeConfiguration struct {
 	maxConcurrency   int
 	waitBetweenPosts time.Duration
 	replayFile       *os.File
+	replayThinkTime  bool
 
 	target       int
 	interviewURL string
 
-	respondentKeyFormat string
+	respondentKeyTemplate *keyTemplate
+	sample                []sampleRow
+
+	breakoffPercentage int
+	breakoffQuestions  []string
+	backPercentage     int
+	clearPercentage    int
+
+	// interview numbers to run; all of 0 to target if not set
+	interviewNumbers []int
+	skipped          int
+	stateFile        *stateFile
 }
 
 type recordConfiguration struct {
 	target       int
 	interviewURL string
 	replayFile   *os.File
+
+	listenAddr    string
+	openBrowser   bool
+	externalHosts []string
+	har           *harLog
+	overlay       bool
 }
 
 var currentStatus *completeStatus
@@ -73,7 +170,9 @@ var recordConfig *recordConfiguration
 var globalConfig *globalConfiguration
 
 /* STUFF WE NEED */
-var random = rand.New(rand.NewSource(time.Now().UnixNano()))
-var errorChannel = make(chan error, 100)
+// random is shared by all workers; don't use its Read method, which is not
+// safe for concurrent use even with a locked source
+var random = rand.New(newLockedSource(time.Now().UnixNano()))
+var collectedErrors = &errorCollector{}
 
 const endOfInterviewPath = "/Home/Completed"
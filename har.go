@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxHARBodySize is the most of a request or response body kept in the
+// log, so long recordings don't keep every page in memory.
+const maxHARBodySize = 256 * 1024
+
+// harLog collects the requests passing through the record proxy and writes
+// them as a HAR 1.2 file, which browser dev tools and load test tools can
+// import.
+type harLog struct {
+	lock    sync.Mutex
+	entries []harEntry
+}
+
+type harFile struct {
+	Log harLogData `json:"log"`
+}
+
+type harLogData struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+
+	// Error is why the request failed without a response
+	Error string `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []harNameValue `json:"params,omitempty"`
+	Text     string         `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harTimings are in milliseconds; -1 means the phase did not happen, e.g.
+// no dns lookup on a reused connection.
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHARLog() *harLog {
+	return &harLog{}
+}
+
+func (log *harLog) add(entry harEntry) {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+
+	log.entries = append(log.entries, entry)
+}
+
+func (log *harLog) write(path string) error {
+	log.lock.Lock()
+	entries := append([]harEntry{}, log.entries...)
+	log.lock.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(harFile{Log: harLogData{
+		Version: "1.2",
+		Creator: harCreator{Name: "complete-interviews", Version: "1.0.0"},
+		Entries: entries,
+	}})
+}
+
+// transport wraps next, so every request and response it handles is added
+// to the log.
+func (log *harLog) transport(next http.RoundTripper) http.RoundTripper {
+	return &harTransport{log: log, next: next}
+}
+
+type harTransport struct {
+	log  *harLog
+	next http.RoundTripper
+}
+
+func (transport *harTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if request.Body != nil && request.Body != http.NoBody {
+		body, err := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		requestBody = body
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	timer := &harTimer{started: time.Now()}
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), timer.trace()))
+
+	response, err := transport.next.RoundTrip(request)
+	if err != nil {
+		timer.set(&timer.finished)
+		transport.log.add(newFailedHAREntry(request, requestBody, timer, err))
+		return nil, err
+	}
+
+	timer.set(&timer.receiveStarted)
+	responseBody, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		timer.set(&timer.finished)
+		transport.log.add(newFailedHAREntry(request, requestBody, timer, err))
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+	timer.set(&timer.finished)
+
+	transport.log.add(newHAREntry(request, requestBody, response, responseBody, timer))
+
+	return response, nil
+}
+
+// harTimer keeps the moments of the phases of a single request.
+type harTimer struct {
+	lock sync.Mutex
+
+	started, dnsStart, dnsDone, connectStart, connectDone time.Time
+	tlsStart, tlsDone, gotConn, wroteRequest, firstByte   time.Time
+	receiveStarted, finished                              time.Time
+}
+
+func (timer *harTimer) set(moment *time.Time) {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+
+	*moment = time.Now()
+}
+
+func (timer *harTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { timer.set(&timer.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { timer.set(&timer.dnsDone) },
+		ConnectStart:         func(string, string) { timer.set(&timer.connectStart) },
+		ConnectDone:          func(string, string, error) { timer.set(&timer.connectDone) },
+		TLSHandshakeStart:    func() { timer.set(&timer.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { timer.set(&timer.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { timer.set(&timer.gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { timer.set(&timer.wroteRequest) },
+		GotFirstResponseByte: func() { timer.set(&timer.firstByte) },
+	}
+}
+
+func (timer *harTimer) timings() harTimings {
+	timer.lock.Lock()
+	defer timer.lock.Unlock()
+
+	milliseconds := func(from time.Time, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return -1
+		}
+		return float64(to.Sub(from)) / float64(time.Millisecond)
+	}
+
+	// without trace information everything until the response counts as
+	// waiting
+	connected := firstMoment(timer.gotConn, timer.started)
+	sent := firstMoment(timer.wroteRequest, connected)
+	firstByte := firstMoment(timer.firstByte, timer.receiveStarted)
+
+	return harTimings{
+		Blocked: milliseconds(timer.started, firstMoment(timer.dnsStart, timer.connectStart, connected)),
+		DNS:     milliseconds(timer.dnsStart, timer.dnsDone),
+		Connect: milliseconds(timer.connectStart, timer.connectDone),
+		SSL:     milliseconds(timer.tlsStart, timer.tlsDone),
+		Send:    milliseconds(connected, sent),
+		Wait:    milliseconds(sent, firstByte),
+		Receive: milliseconds(timer.receiveStarted, timer.finished),
+	}
+}
+
+func firstMoment(moments ...time.Time) time.Time {
+	for _, moment := range moments {
+		if !moment.IsZero() {
+			return moment
+		}
+	}
+
+	return time.Time{}
+}
+
+func newHAREntry(request *http.Request, requestBody []byte, response *http.Response, responseBody []byte, timer *harTimer) harEntry {
+	entry := newHARRequestEntry(request, requestBody, timer)
+	entry.Response = harResponse{
+		Status:      response.StatusCode,
+		StatusText:  http.StatusText(response.StatusCode),
+		HTTPVersion: response.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(response.Header),
+		Content:     harBodyContent(response.Header.Get("Content-Type"), responseBody),
+		RedirectURL: response.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(responseBody),
+	}
+
+	for _, cookie := range response.Cookies() {
+		entry.Response.Cookies = append(entry.Response.Cookies, harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	return entry
+}
+
+// newFailedHAREntry logs a request that got no (complete) response. HAR
+// viewers show these with status 0.
+func newFailedHAREntry(request *http.Request, requestBody []byte, timer *harTimer, err error) harEntry {
+	entry := newHARRequestEntry(request, requestBody, timer)
+	entry.Response = harResponse{
+		Cookies:     []harNameValue{},
+		Headers:     []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	entry.Error = err.Error()
+
+	return entry
+}
+
+func newHARRequestEntry(request *http.Request, requestBody []byte, timer *harTimer) harEntry {
+	entry := harEntry{
+		StartedDateTime: timer.started,
+		Time:            float64(timer.finished.Sub(timer.started)) / float64(time.Millisecond),
+		Request: harRequest{
+			Method:      request.Method,
+			URL:         request.URL.String(),
+			HTTPVersion: request.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(request.Header),
+			HeadersSize: -1,
+			BodySize:    len(requestBody),
+		},
+		Timings: timer.timings(),
+	}
+
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+
+	for _, cookie := range request.Cookies() {
+		entry.Request.Cookies = append(entry.Request.Cookies, harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	entry.Request.QueryString = harValues(request.URL.Query())
+
+	if requestBody != nil {
+		entry.Request.PostData = harRequestPostData(request.Header.Get("Content-Type"), requestBody)
+	}
+
+	return entry
+}
+
+func harRequestPostData(contentType string, body []byte) *harPostData {
+	if truncated, ok := truncateHARBody(body); ok {
+		// params of a cut off form would be wrong
+		return &harPostData{MimeType: contentType, Text: string(truncated)}
+	}
+
+	postData := &harPostData{MimeType: contentType, Text: string(body)}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			postData.Params = harValues(form)
+		}
+	}
+
+	return postData
+}
+
+func harBodyContent(contentType string, body []byte) harContent {
+	content := harContent{Size: len(body), MimeType: contentType}
+
+	if truncated, ok := truncateHARBody(body); ok {
+		body = truncated
+		content.Comment = fmt.Sprintf("truncated to the first %d bytes", len(body))
+	}
+
+	if utf8.Valid(body) && isTextContent(contentType) {
+		content.Text = string(body)
+	} else if len(body) > 0 {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	return content
+}
+
+// truncateHARBody cuts body to at most maxHARBodySize bytes, without
+// splitting a utf-8 character.
+func truncateHARBody(body []byte) ([]byte, bool) {
+	if len(body) <= maxHARBodySize {
+		return body, false
+	}
+
+	cut := maxHARBodySize
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+
+	return body[:cut], true
+}
+
+func isTextContent(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	return mediaType == "" ||
+		strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		strings.HasSuffix(mediaType, "javascript") ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+func harValues(values url.Values) []harNameValue {
+	return harHeaders(http.Header(values))
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	result := []harNameValue{}
+	for key, values := range header {
+		for _, value := range values {
+			result = append(result, harNameValue{Name: key, Value: value})
+		}
+	}
+	sortHARValues(result)
+
+	return result
+}
+
+func sortHARValues(values []harNameValue) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Name < values[j].Name
+	})
+}
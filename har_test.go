@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHARTransportRecordsRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	remote := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		http.SetCookie(response, &http.Cookie{Name: "session", Value: "abc"})
+		response.Header().Set("Content-Type", "text/html")
+		response.Write([]byte("<p>page</p>"))
+	}))
+	defer remote.Close()
+
+	har := newHARLog()
+	client := http.Client{Transport: har.transport(http.DefaultTransport)}
+
+	response, err := client.PostForm(remote.URL+"/post?id=1", url.Values{"answer-q1": {"yes"}})
+	assert.NoError(err)
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Equal("<p>page</p>", string(body))
+
+	assert.Len(har.entries, 1)
+	entry := har.entries[0]
+
+	assert.Equal("POST", entry.Request.Method)
+	assert.Equal(remote.URL+"/post?id=1", entry.Request.URL)
+	assert.Equal([]harNameValue{{Name: "id", Value: "1"}}, entry.Request.QueryString)
+	assert.Equal([]harNameValue{{Name: "answer-q1", Value: "yes"}}, entry.Request.PostData.Params)
+	assert.Equal("answer-q1=yes", entry.Request.PostData.Text)
+
+	assert.Equal(200, entry.Response.Status)
+	assert.Equal("<p>page</p>", entry.Response.Content.Text)
+	assert.Empty(entry.Response.Content.Encoding)
+	assert.Equal([]harNameValue{{Name: "session", Value: "abc"}}, entry.Response.Cookies)
+
+	assert.True(entry.Time >= 0)
+	assert.True(entry.Timings.Wait >= 0)
+	assert.True(entry.Timings.Receive >= 0)
+}
+
+func TestHARBodyContentEncodesBinaryData(t *testing.T) {
+	assert := assert.New(t)
+
+	content := harBodyContent("image/png", []byte{0x89, 'P', 'N', 'G'})
+	assert.Equal("base64", content.Encoding)
+	assert.Equal("iVBORw==", content.Text)
+	assert.Equal(4, content.Size)
+
+	content = harBodyContent("application/json; charset=utf-8", []byte(`{"a":1}`))
+	assert.Empty(content.Encoding)
+	assert.Equal(`{"a":1}`, content.Text)
+}
+
+func TestHARLogWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "har")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	har := newHARLog()
+	har.add(harEntry{Request: harRequest{Method: "GET", URL: "https://example.com/"}})
+
+	path := filepath.Join(dir, "recording.har")
+	assert.NoError(har.write(path))
+
+	file, err := os.Open(path)
+	assert.NoError(err)
+	defer file.Close()
+
+	var result map[string]map[string]interface{}
+	assert.NoError(json.NewDecoder(file).Decode(&result))
+
+	assert.Equal("1.2", result["log"]["version"])
+	entries := result["log"]["entries"].([]interface{})
+	assert.Len(entries, 1)
+
+	request := entries[0].(map[string]interface{})["request"].(map[string]interface{})
+	assert.True(strings.HasPrefix(request["url"].(string), "https://example.com"))
+}
+
+func TestHARTransportRecordsFailedRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	closedURL := "http://" + listener.Addr().String() + "/start"
+	listener.Close()
+
+	har := newHARLog()
+	client := http.Client{Transport: har.transport(http.DefaultTransport)}
+
+	_, err = client.Get(closedURL)
+	assert.Error(err)
+
+	assert.Len(har.entries, 1)
+	assert.Equal(closedURL, har.entries[0].Request.URL)
+	assert.Equal(0, har.entries[0].Response.Status)
+	assert.Contains(har.entries[0].Error, "refused")
+}
+
+func TestHARBodyContentIsTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	body := []byte(strings.Repeat("a", maxHARBodySize-1) + "é and more")
+	content := harBodyContent("text/html", body)
+
+	assert.Equal(len(body), content.Size)
+	assert.Equal(strings.Repeat("a", maxHARBodySize-1), content.Text, "characters are not split")
+	assert.Empty(content.Encoding)
+	assert.Contains(content.Comment, "truncated")
+
+	postData := harRequestPostData("application/x-www-form-urlencoded", body)
+	assert.Len(postData.Text, maxHARBodySize-1)
+	assert.Empty(postData.Params)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
@@ -105,7 +106,7 @@ func flattenURLValues(values url.Values) map[string]string {
 	return result
 }
 
-func handleRequest(t *testing.T, path string, numberOfRequests *int) pageContent {
+func handleRequest(t *testing.T, path string, numberOfRequests *int) (pageContent, error) {
 	(*numberOfRequests)++
 
 	fileName := filepath.Join(path, fmt.Sprintf("page%d.html", *numberOfRequests))
@@ -119,12 +120,12 @@ func handleRequest(t *testing.T, path string, numberOfRequests *int) pageContent
 	bytes, err := ioutil.ReadFile(fileName)
 
 	if err != nil {
-		return pageContent{err: err}
+		return pageContent{}, err
 	}
 
 	content := string(bytes)
 
-	return pageContent{body: &content, url: &url}
+	return pageContent{body: &content, url: &url}, nil
 }
 
 func isLastFile(path string, number *int) bool {
@@ -154,11 +155,11 @@ func setupMocking(t *testing.T, path string, numberOfRequests *int) {
 		replaySteps:      nil,
 	}
 
-	postContent = func(url *string, body url.Values, ch chan pageContent) {
-		ch <- handleRequest(t, *url, numberOfRequests)
+	postContent = func(client http.Client, url *string, body url.Values) (pageContent, error) {
+		return handleRequest(t, *url, numberOfRequests)
 	}
 
-	getContent = func(url *string, ch chan pageContent) {
-		ch <- handleRequest(t, *url, numberOfRequests)
+	getContent = func(client http.Client, url *string) (pageContent, error) {
+		return handleRequest(t, *url, numberOfRequests)
 	}
 }
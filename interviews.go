@@ -3,20 +3,21 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
-	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/html"
 )
 
 type pageContent struct {
-	body *string
-	url  *string
+	body   *string
+	url    *string
+	status int
 }
 
 type interviewToComplete struct {
@@ -29,14 +30,20 @@ func processInterviews() {
 	chResults := make(chan error, completeConfig.target)
 
 	go func() {
-		var replaySteps []url.Values
+		var replaySteps []replayStep
 		if completeConfig.replayFile != nil {
 			replaySteps = parseReplayFile(completeConfig.replayFile)
 			currentStatus.replaySteps = &replaySteps
 		}
 
-		for i := 0; i < completeConfig.target; i++ {
-			chInterviews <- interviewToComplete{url: &completeConfig.interviewURL, number: i}
+		if completeConfig.interviewNumbers != nil {
+			for _, number := range completeConfig.interviewNumbers {
+				chInterviews <- interviewToComplete{url: &completeConfig.interviewURL, number: number}
+			}
+		} else {
+			for i := 0; i < completeConfig.target; i++ {
+				chInterviews <- interviewToComplete{url: &completeConfig.interviewURL, number: i}
+			}
 		}
 
 		for i := 0; i < completeConfig.maxConcurrency; i++ {
@@ -47,32 +54,42 @@ func processInterviews() {
 					time.Sleep(50 * time.Millisecond)
 				}
 			}
-			go func(in chan interviewToComplete, out chan error) {
+			go func(worker int, in chan interviewToComplete, out chan error) {
 				printVerbose("thread", "Starting thread...\n")
+				atomic.AddInt64(&currentStatus.workers, 1)
+				defer atomic.AddInt64(&currentStatus.workers, -1)
 
 				cookieJar, _ := cookiejar.New(nil)
-				client := http.Client{
-					Timeout: globalConfig.requestTimeout,
-					Jar:     cookieJar,
-				}
+				client := newHTTPClient(cookieJar)
 
 				for len(in) > 0 {
 					nextInterview := <-in
+					tracker := newInterviewTracker(nextInterview.number, getRespondentKey(nextInterview.number))
+					tracker.worker = worker
+					tracker.start()
 
 					var err error
 					if globalConfig.command == "complete" {
-						err = performInterview(client, nextInterview.url, nextInterview.number)
+						err = performInterview(client, nextInterview.url, tracker)
 					} else if globalConfig.command == "replay" {
-						err = performReplay(client, nextInterview.url)
+						err = performReplay(client, nextInterview.url, tracker)
 					} else {
 						err = fmt.Errorf("Unknown command")
 					}
 
+					tracker.finish(err)
+
+					if completeConfig.stateFile != nil {
+						if stateErr := completeConfig.stateFile.record(nextInterview.number, tracker.respondentKey, tracker.abandoned, err); stateErr != nil {
+							printOutputError(stateErr)
+						}
+					}
+
 					out <- err
 				}
 
 				printVerbose("thread", "Thread finished.\n")
-			}(chInterviews, chResults)
+			}(i, chInterviews, chResults)
 		}
 	}()
 
@@ -89,66 +106,90 @@ func processInterviews() {
 		currentStatus.completed++
 
 		if err != nil {
-			printError(err)
 			currentStatus.errored++
+			printError(err)
 		}
 	}
 
 	currentStatus.active = 0
 }
 
-func performReplay(client http.Client, url *string) error {
-	result, err := getContent(client, url)
+func performReplay(client http.Client, url *string, tracker *interviewTracker) error {
+	startURL, err := getStartURL(*url, tracker.number)
 
 	if err != nil {
 		return err
 	}
 
-	findScreenID := func(content pageContent) (string, error) {
-		foundScreenID := ""
-		doc, err := html.Parse(strings.NewReader(*content.body))
+	result, err := tracker.get(client, &startURL)
 
-		if err != nil {
-			return foundScreenID, err
-		}
-
-		walkDocumentByTag(doc, "input", func(input *html.Node) {
-			attrs := attrsToMap(input.Attr)
-
-			if attrs["id"] == "screenId" {
-				foundScreenID = attrs["value"]
-			}
-		})
-
-		return foundScreenID, nil
+	if err != nil {
+		return err
 	}
 
-	screenID, err := findScreenID(result)
+	page, err := getReplayPage(result)
 
 	if err != nil {
 		return err
 	}
 
-	for _, answers := range *currentStatus.replaySteps {
+	steps := *currentStatus.replaySteps
+	for index := 0; index < len(steps); index++ {
 		if strings.Contains(*result.url, endOfInterviewPath) {
 			// start new interview; replay contained multiple
 			printVerbose("replay", "Starting new interview, because replay file is longer.\n")
-			result, err := getContent(client, url)
+			result, err = tracker.get(client, &startURL)
 
 			if err != nil {
 				return err
 			}
 
-			screenID, err = findScreenID(result)
+			page, err = getReplayPage(result)
 
 			if err != nil {
 				return err
 			}
 		}
 
-		response := addScreenID(answers, screenID)
+		step := steps[index]
+
+		// recordings with page context are matched on the questions, so
+		// pages that are skipped by routing don't break the replay
+		if len(step.questions) > 0 && !sameQuestions(step.questions, page.questions) {
+			match := findReplayStep(steps, index, page.questions)
+
+			if match < 0 {
+				return &replayMismatchError{message: fmt.Sprintf(
+					"replay file has no step for questions %s (expected %s)",
+					strings.Join(page.questions, ", "), strings.Join(step.questions, ", "))}
+			}
+
+			printVerbose("replay", "Skipping %d step(s) to answer %v\n", match-index, page.questions)
+			index = match
+			step = steps[index]
+		}
+
+		if completeConfig.replayThinkTime && step.thinkTime > 0 {
+			time.Sleep(step.thinkTime)
+		}
+
+		answers, err := applyReplayAnnotations(step, result.body, tracker.number)
+
+		if err != nil {
+			return err
+		}
+
+		tracker.questionDetected(pageInfo{questionType: page.questionType, questions: page.questions})
+
+		response := addScreenID(answers, page.screenID)
 		printVerbose("replay", "posting %v\n", response)
-		result, err = postContent(client, result.url, answers)
+		result, err = tracker.post(client, result.url, response)
+
+		if err != nil {
+			return err
+		}
+
+		page, err = getReplayPage(result)
 
 		if err != nil {
 			return err
@@ -156,93 +197,147 @@ func performReplay(client http.Client, url *string) error {
 	}
 
 	if !strings.Contains(*result.url, endOfInterviewPath) {
-		return fmt.Errorf("end of replay file did not result in completed interview")
+		return &replayMismatchError{message: "end of replay file did not result in completed interview"}
 	}
 
 	return nil
 }
 
-func performInterview(client http.Client, url *string, number int) error {
-	startURL := *url
-	if completeConfig.respondentKeyFormat != "" {
-		if !strings.HasSuffix(startURL, "/") {
-			startURL += "/"
+type replayPage struct {
+	screenID     string
+	questionType string
+	questions    []string
+}
+
+func getReplayPage(content pageContent) (replayPage, error) {
+	page := replayPage{}
+	doc, err := html.Parse(strings.NewReader(*content.body))
+
+	if err != nil {
+		return page, err
+	}
+
+	walkDocumentByTag(doc, "input", func(input *html.Node) {
+		attrs := attrsToMap(input.Attr)
+
+		if attrs["id"] == "screenId" {
+			page.screenID = attrs["value"]
 		}
-		startURL += fmt.Sprintf(completeConfig.respondentKeyFormat, number)
+	})
+
+	page.questionType = getQuestionType(doc)
+	page.questions = getQuestionIDs(doc)
+
+	return page, nil
+}
+
+func performInterview(client http.Client, url *string, tracker *interviewTracker) error {
+	number := tracker.number
+	startURL, err := getStartURL(*url, number)
+
+	if err != nil {
+		return err
 	}
 
-	result, err := getContent(client, &startURL)
+	result, err := tracker.get(client, &startURL)
 
 	if err != nil {
 		return err
 	}
 
+	navigation := newInterviewNavigation()
 	prevHistoryOrder := ""
 	hasAnotherQuestion := !strings.Contains(*result.url, endOfInterviewPath)
 	for hasAnotherQuestion {
-		newRequest, historyOrder, err := getInterviewResponse(result.body, prevHistoryOrder)
+		if navigation.isActive() {
+			action, navigationRequest, err := navigation.nextAction(result.body)
 
+			if err != nil {
+				return err
+			}
+
+			if action == navigationAbandon {
+				printVerbose("navigation", "Abandoning interview %d\n", number)
+				markInterviewAbandoned()
+				tracker.abandon()
+				return nil
+			}
+
+			if navigationRequest != nil {
+				printVerbose("navigation", "Pressing %s in interview %d\n", action, number)
+				result, err = tracker.post(client, result.url, navigationRequest)
+
+				if err != nil {
+					return err
+				}
+
+				// the page we come back to has a different (or the same)
+				// history order, which is not a validation error
+				hasAnotherQuestion = !strings.Contains(*result.url, endOfInterviewPath)
+				prevHistoryOrder = ""
+				continue
+			}
+		}
+
+		newRequest, page, err := getInterviewResponse(result.body, prevHistoryOrder)
+
+		if err == errAnswerRejected {
+			tracker.answerRejected(err)
+		}
 		if err != nil {
 			return err
 		}
 
-		result, err = postContent(client, result.url, newRequest)
+		tracker.questionDetected(page)
+		result, err = tracker.post(client, result.url, newRequest)
 
 		if err != nil {
 			return err
 		}
 
 		hasAnotherQuestion = !strings.Contains(*result.url, endOfInterviewPath)
-		prevHistoryOrder = historyOrder
+		prevHistoryOrder = page.historyOrder
 	}
 
 	return nil
 }
 
-func addScreenID(form url.Values, screenID string) url.Values {
-	result := url.Values{}
-	result.Set("screenId", screenID)
+func getStartURL(interviewURL string, number int) (string, error) {
+	if completeConfig.sample != nil {
+		return getSampleURL(interviewURL, completeConfig.sample[number])
+	}
 
-	for key, values := range form {
-		for _, value := range values {
-			result.Add(key, value)
+	startURL := interviewURL
+	if respondentKey := getRespondentKey(number); respondentKey != "" {
+		if !strings.HasSuffix(startURL, "/") {
+			startURL += "/"
 		}
+		startURL += respondentKey
 	}
 
-	return result
+	return startURL, nil
 }
 
-func parseReplayFile(file *os.File) []url.Values {
-	buf := bytes.NewBuffer(nil)
-	io.Copy(buf, file) // Error handling elided for brevity.
-
-	questions := strings.Split(string(buf.Bytes()), "---\n")
-	answers := []url.Values{}
+func getRespondentKey(number int) string {
+	if completeConfig.sample != nil {
+		return completeConfig.sample[number].respondentKey
+	}
 
-	for _, question := range questions {
-		if strings.TrimSpace(question) != "" {
-			answers = append(answers, parseReplayQuestion(question))
-		}
+	if completeConfig.respondentKeyTemplate == nil {
+		return ""
 	}
 
-	return answers
+	return completeConfig.respondentKeyTemplate.format(number)
 }
 
-func parseReplayQuestion(question string) url.Values {
-	lines := strings.FieldsFunc(question, func(char rune) bool { return char == '\n' })
-	printVerbose("replay", "question\n")
+func addScreenID(form url.Values, screenID string) url.Values {
 	result := url.Values{}
+	result.Set("screenId", screenID)
 
-	for _, line := range lines {
-		splitLine := strings.Split(line, "=")
-		key := splitLine[0]
-		valuesString := splitLine[1]
-
-		values := strings.Trim(valuesString, "[]")
-
-		printVerbose("replay", "key: %s, value: %s\n", key, values)
-
-		result.Set(key, values)
+	for key, values := range form {
+		for _, value := range values {
+			result.Add(key, value)
+		}
 	}
 
 	return result
@@ -255,26 +350,47 @@ var postContent = func(client http.Client, url *string, body url.Values) (pageCo
 	}
 
 	printVerbose("post", "content: %s\n", body)
-	response, err := client.Post(*url, "application/x-www-form-urlencoded", strings.NewReader(body.Encode()))
 
-	if err != nil {
-		return pageContent{}, err
-	}
+	return withRetries(func() (pageContent, error) {
+		request, err := http.NewRequest("POST", *url, strings.NewReader(body.Encode()))
+
+		if err != nil {
+			return pageContent{}, err
+		}
+
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	result, err := handleHTTPResult(response)
-	return result, err
+		// answers must not be posted twice, so a post is only retried
+		// when it failed before anything was sent
+		var sent int32
+		request = request.WithContext(httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{
+			WroteHeaders: func() { atomic.StoreInt32(&sent, 1) },
+		}))
+
+		response, err := client.Do(request)
+
+		if err != nil {
+			if atomic.LoadInt32(&sent) != 0 {
+				return pageContent{}, &sentRequestError{err: err}
+			}
+			return pageContent{}, err
+		}
+
+		return handleHTTPResult(response)
+	})
 }
 
 /* mockable */
 var getContent = func(client http.Client, url *string) (pageContent, error) {
-	response, err := client.Get(*url)
+	return withRetries(func() (pageContent, error) {
+		response, err := client.Get(*url)
 
-	if err != nil {
-		return pageContent{}, err
-	}
+		if err != nil {
+			return pageContent{}, err
+		}
 
-	result, err := handleHTTPResult(response)
-	return result, err
+		return handleHTTPResult(response)
+	})
 }
 
 func handleHTTPResult(response *http.Response) (pageContent, error) {
@@ -287,10 +403,22 @@ func handleHTTPResult(response *http.Response) (pageContent, error) {
 
 	str := buf.String()
 
-	result := pageContent{body: &str, url: &url}
+	result := pageContent{body: &str, url: &url, status: response.StatusCode}
 
 	if response.StatusCode >= 400 {
-		return pageContent{}, fmt.Errorf("http request was unsuccessful: %s (url: %s)", response.Status, url)
+		// the method of the request we sent, not of the redirects after it
+		request := response.Request
+		for request.Response != nil {
+			request = request.Response.Request
+		}
+
+		return pageContent{}, &httpStatusError{
+			status:     response.Status,
+			statusCode: response.StatusCode,
+			url:        url,
+			method:     request.Method,
+			retryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+		}
 	}
 
 	return result, nil
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,7 +13,7 @@ func TestCompleteInterviews(t *testing.T) {
 
 	assert := assert.New(t)
 
-	err := performInterview(&completeConfig.interviewURL, 0)
+	err := performInterview(http.Client{}, &completeConfig.interviewURL, newInterviewTracker(0, ""))
 	assert.NoError(err)
 
 	assert.Equal(13, numberOfRequests)
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	requestRateWindow   = 10 * time.Second
+	interviewRateWindow = time.Minute
+	maxRecentErrors     = 5
+)
+
+type workerState struct {
+	interview     int
+	respondentKey string
+	page          int
+	question      string
+	busy          bool
+}
+
+// liveStatus keeps what the live display shows about the workers and the
+// throughput of the run.
+type liveStatus struct {
+	lock sync.Mutex
+
+	started      time.Time
+	workers      map[int]*workerState
+	requests     []time.Time
+	finished     []time.Time
+	recentErrors []string
+}
+
+func newLiveStatus() *liveStatus {
+	return &liveStatus{started: time.Now(), workers: make(map[int]*workerState)}
+}
+
+var currentLiveStatus = newLiveStatus()
+
+func (status *liveStatus) handleEvent(event interviewEvent) {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	worker, ok := status.workers[event.Worker]
+	if !ok {
+		worker = &workerState{}
+		status.workers[event.Worker] = worker
+	}
+
+	switch event.Event {
+	case eventInterviewStarted:
+		*worker = workerState{interview: event.Interview, respondentKey: event.RespondentKey, busy: true}
+	case eventQuestionDetected:
+		worker.page++
+		worker.question = event.QuestionType
+		if len(event.Questions) > 0 {
+			worker.question = fmt.Sprintf("%s (%s)", strings.Join(event.Questions, ", "), event.QuestionType)
+		}
+	case eventPageFetched:
+		status.requests = append(status.requests, event.Time)
+	case eventInterviewCompleted, eventInterviewAbandoned, eventInterviewErrored:
+		worker.busy = false
+		status.finished = append(status.finished, event.Time)
+	}
+}
+
+// addErrors remembers the most recent distinct error messages.
+func (status *liveStatus) addErrors(errs []error) {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	for _, err := range errs {
+		message := err.Error()
+
+		for i, recent := range status.recentErrors {
+			if recent == message {
+				status.recentErrors = append(status.recentErrors[:i], status.recentErrors[i+1:]...)
+				break
+			}
+		}
+
+		status.recentErrors = append(status.recentErrors, message)
+		if len(status.recentErrors) > maxRecentErrors {
+			status.recentErrors = status.recentErrors[1:]
+		}
+	}
+}
+
+func countSince(times []time.Time, since time.Time) ([]time.Time, int) {
+	first := sort.Search(len(times), func(i int) bool { return times[i].After(since) })
+
+	return times[first:], len(times) - first
+}
+
+// rates returns requests per second and interviews per minute, over a
+// rolling window.
+func (status *liveStatus) rates(now time.Time) (float64, float64) {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	var requestCount, interviewCount int
+	status.requests, requestCount = countSince(status.requests, now.Add(-requestRateWindow))
+	status.finished, interviewCount = countSince(status.finished, now.Add(-interviewRateWindow))
+
+	requestWindow := minDuration(requestRateWindow, now.Sub(status.started))
+	interviewWindow := minDuration(interviewRateWindow, now.Sub(status.started))
+
+	if requestWindow <= 0 || interviewWindow <= 0 {
+		return 0, 0
+	}
+
+	return float64(requestCount) / requestWindow.Seconds(), float64(interviewCount) / interviewWindow.Minutes()
+}
+
+// eta estimates the remaining time from the average pace of the run so far.
+func (status *liveStatus) eta(now time.Time, completed int, target int) (time.Duration, bool) {
+	if completed == 0 || completed >= target {
+		return 0, false
+	}
+
+	elapsed := now.Sub(status.started)
+	perInterview := elapsed / time.Duration(completed)
+
+	return perInterview * time.Duration(target-completed), true
+}
+
+func (status *liveStatus) workerLines() []string {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	ids := []int{}
+	for id := range status.workers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	lines := []string{}
+	for _, id := range ids {
+		worker := status.workers[id]
+
+		if !worker.busy {
+			lines = append(lines, fmt.Sprintf("Worker %2d  : idle", id+1))
+			continue
+		}
+
+		interview := fmt.Sprintf("#%d", worker.interview)
+		if worker.respondentKey != "" {
+			interview += " (" + worker.respondentKey + ")"
+		}
+
+		page := "starting"
+		if worker.page > 0 {
+			page = fmt.Sprintf("page %d: %s", worker.page, worker.question)
+		}
+
+		lines = append(lines, fmt.Sprintf("Worker %2d  : %s, %s", id+1, interview, page))
+	}
+
+	return lines
+}
+
+func (status *liveStatus) errorLines() []string {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	return append([]string{}, status.recentErrors...)
+}
+
+func minDuration(a time.Duration, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveStatusWorkers(t *testing.T) {
+	assert := assert.New(t)
+
+	status := newLiveStatus()
+	eventListeners = []eventListener{status.handleEvent}
+	defer func() { eventListeners = nil }()
+
+	first := newInterviewTracker(4, "key4")
+	first.worker = 0
+	first.start()
+	first.questionDetected(pageInfo{questionType: qTypeNumber, questions: []string{"q30"}})
+
+	second := newInterviewTracker(5, "")
+	second.worker = 1
+	second.start()
+	second.finish(nil)
+
+	assert.Equal([]string{
+		"Worker  1  : #4 (key4), page 1: q30 (Number)",
+		"Worker  2  : idle",
+	}, status.workerLines())
+}
+
+func TestLiveStatusRates(t *testing.T) {
+	assert := assert.New(t)
+
+	status := newLiveStatus()
+	now := time.Now()
+	status.started = now.Add(-2 * time.Minute)
+
+	for i := 19; i >= 0; i-- {
+		status.requests = append(status.requests, now.Add(-time.Duration(i)*time.Second))
+	}
+	for i := 0; i < 30; i++ {
+		status.finished = append(status.finished, now.Add(-time.Duration(90-i)*time.Second))
+	}
+	for i := 5; i >= 0; i-- {
+		status.finished = append(status.finished, now.Add(-time.Duration(10*i+5)*time.Second))
+	}
+
+	requestRate, interviewRate := status.rates(now)
+	assert.InDelta(1.0, requestRate, 0.01)
+	assert.InDelta(6.0, interviewRate, 0.01)
+
+	eta, ok := status.eta(now, 30, 40)
+	assert.True(ok)
+	assert.Equal(40*time.Second, eta)
+
+	_, ok = status.eta(now, 0, 40)
+	assert.False(ok)
+}
+
+func TestLiveStatusRecentErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	status := newLiveStatus()
+
+	for i := 0; i < 7; i++ {
+		status.addErrors([]error{fmt.Errorf("error %d", i)})
+	}
+	status.addErrors([]error{fmt.Errorf("error 3")})
+
+	assert.Equal([]string{"error 2", "error 4", "error 5", "error 6", "error 3"}, status.errorLines())
+}
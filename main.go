@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -18,17 +19,110 @@ func main() {
 	kingpin.CommandLine.HelpFlag.Short('h')
 
 	command := kingpin.Parse()
+	if command == replayRunCommand.FullCommand() {
+		command = "replay"
+	}
 
 	globalConfig = &globalConfiguration{
 		requestTimeout: *requestTimeoutFlag,
 		verboseOutput:  *verboseOutputFlag,
 		command:        command,
+
+		outputMode:       getOutputMode(*outputModeFlag, terminal.IsTerminal(int(os.Stdout.Fd())), *verboseOutputFlag),
+		progressInterval: *progressEveryFlag,
+
+		maxRetries:   *maxRetriesFlag,
+		retryWait:    *retryWaitFlag,
+		maxRetryWait: *maxRetryWaitFlag,
+	}
+
+	if globalConfig.progressInterval <= 0 {
+		globalConfig.progressInterval = 10 * time.Second
+	}
+
+	transport, err := buildTransport(transportConfiguration{
+		caCertFile:     *caCertFlag,
+		insecure:       *insecureFlag,
+		clientCertFile: *clientCertFlag,
+		clientKeyFile:  *clientKeyFlag,
+		proxyURL:       *proxyFlag,
+		interviewURL:   getCommandInterviewURL(command),
+		headers:        *headerFlag,
+		basicAuth:      *basicAuthFlag,
+		bearerToken:    *bearerTokenFlag,
+		userAgents:     *userAgentFlag,
+	})
+	if err != nil {
+		kingpin.FatalUsage("%v", err)
+	}
+	globalConfig.transport = transport
+
+	if *logFileFlag != "" {
+		listener, err := openEventLog(*logFileFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		addEventListener(listener)
 	}
 
-	// If stdout is redirected, we want verbose
-	// output because the other output is useless
-	if !terminal.IsTerminal(int(os.Stdout.Fd())) {
-		globalConfig.verboseOutput = true
+	if *artefactsDirFlag != "" {
+		globalConfig.artefactsDir = *artefactsDirFlag
+		addEventListener(newArtefactCollector(*artefactsDirFlag, *artefactsPagesFlag))
+	}
+
+	if *metricsAddrFlag != "" && isCompletingInterviews() {
+		metrics := newRunMetrics()
+		addr, err := serveMetrics(*metricsAddrFlag, metrics)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		printInfo("Serving metrics on http://%s/metrics", addr)
+		addEventListener(metrics.handleEvent)
+	}
+
+	if *answersReportFlag != "" && isCompletingInterviews() {
+		report := newAnswersReport(*answersReportFlag)
+		addEventListener(report.handleEvent)
+		addRunFinishedListener(func() {
+			if err := report.write(); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not write answers report: %v\n", err)
+			}
+		})
+	}
+
+	if *responsesCSVFlag != "" && isCompletingInterviews() {
+		export := newResponsesExport(*responsesCSVFlag)
+		addEventListener(export.handleEvent)
+		addRunFinishedListener(func() {
+			if err := export.write(); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not write responses: %v\n", err)
+			}
+		})
+	}
+
+	if *completeReplayDirFlag != "" && command == "complete" {
+		listener, err := newReplayExport(*completeReplayDirFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		addEventListener(listener)
+	}
+
+	if isTTYOutput() && isCompletingInterviews() {
+		addEventListener(currentLiveStatus.handleEvent)
+	}
+
+	if *reportFileFlag != "" {
+		report := newCIReport(*reportFileFlag, *reportFormatFlag, *reportStepsFlag)
+		addEventListener(report.handleEvent)
+		addRunFinishedListener(func() {
+			if err := report.write(); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not write report: %v\n", err)
+			}
+		})
 	}
 
 	c := make(chan os.Signal, 1)
@@ -36,6 +130,7 @@ func main() {
 	go func() {
 		<-c
 		clearScreen()
+		finishRun()
 		printFinalMessage("Interrupted.")
 
 		os.Exit(1)
@@ -48,6 +143,8 @@ func main() {
 		executeRecordCommand()
 	case "replay":
 		executeReplayCommand()
+	default:
+		executeReplayToolCommand(command)
 	}
 }
 
@@ -59,6 +156,11 @@ func executeRecordCommand() {
 		interviewURL: *recordInterviewURLArg,
 		target:       *recordTargetArg,
 		replayFile:   file,
+		listenAddr:   *recordListenFlag,
+		openBrowser:  !*recordNoBrowserFlag,
+
+		externalHosts: *recordExternalHostsFlag,
+		overlay:       !*recordNoOverlayFlag,
 	}
 
 	if err != nil {
@@ -66,7 +168,22 @@ func executeRecordCommand() {
 		os.Exit(1)
 	}
 
-	startProxyForInterview()
+	if *recordHARFileFlag != "" {
+		recordConfig.har = newHARLog()
+		addRunFinishedListener(func() {
+			if err := recordConfig.har.write(*recordHARFileFlag); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not write HAR file: %v\n", err)
+			}
+		})
+	}
+
+	err = startProxyForInterview()
+	finishRun()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
 	printFinalMessage("Done.")
 }
@@ -81,22 +198,46 @@ func executeCompleteCommand() {
 		interviewURL: *completeInterviewURLArg,
 		target:       *completeTargetArg,
 
-		waitBetweenPosts:    *completeWaitBetweenPostsFlag,
-		maxConcurrency:      *completeMaxConcurrencyFlag,
-		respondentKeyFormat: getGolangFormat(*completeRespondentKeyFormatFlag),
+		waitBetweenPosts: *completeWaitBetweenPostsFlag,
+		maxConcurrency:   *completeMaxConcurrencyFlag,
+
+		breakoffPercentage: getPercentage("breakoff", *completeBreakoffFlag),
+		breakoffQuestions:  *completeBreakoffQuestionsFlag,
+		backPercentage:     getPercentage("back", *completeBackFlag),
+		clearPercentage:    getPercentage("clear", *completeClearFlag),
 	}
 
+	if len(completeConfig.breakoffQuestions) > 0 && completeConfig.breakoffPercentage == 0 {
+		kingpin.FatalUsage("--breakoff-question requires --breakoff.")
+	}
+
+	if *completeRespondentKeyFormatFlag != "" {
+		runID := *completeRunIDFlag
+		if runID == "" {
+			runID = newRunID()
+		}
+
+		template, err := parseKeyTemplate(*completeRespondentKeyFormatFlag, runID)
+		if err != nil {
+			kingpin.FatalUsage("%v", err)
+		}
+		completeConfig.respondentKeyTemplate = template
+	}
+
+	applySampleFile(*completeSampleFileFlag, *completeSampleKeyColumnFlag)
+	applyStateFile(*completeStateFileFlag, *completeErroredOnlyFlag)
 	ensureConsistentCompleteOptions()
 	printFirstMessage()
 
 	go startOutputLoop()
 
 	processInterviews()
+	finishRun()
 
 	clearScreen()
 	printFinalMessage("Finished.")
 
-	if currentStatus.errored > 0 {
+	if hasRunFailed() {
 		os.Exit(1)
 	}
 }
@@ -111,9 +252,9 @@ func executeReplayCommand() {
 		interviewURL: *replayInterviewURLArg,
 		target:       *replayTargetArg,
 
-		waitBetweenPosts:    *replayWaitBetweenPostsFlag,
-		maxConcurrency:      *replayMaxConcurrencyFlag,
-		respondentKeyFormat: "",
+		waitBetweenPosts: *replayWaitBetweenPostsFlag,
+		maxConcurrency:   *replayMaxConcurrencyFlag,
+		replayThinkTime:  *replayThinkTimeFlag,
 	}
 
 	file, err := os.Open(*replayFileArg)
@@ -123,19 +264,78 @@ func executeReplayCommand() {
 	}
 	completeConfig.replayFile = file
 
+	applySampleFile(*replaySampleFileFlag, *replaySampleKeyColumnFlag)
+	applyStateFile(*replayStateFileFlag, *replayErroredOnlyFlag)
 	ensureConsistentCompleteOptions()
 	printFirstMessage()
 
 	go startOutputLoop()
 
 	processInterviews()
+	finishRun()
 
 	clearScreen()
 	printFinalMessage("Finished.")
 
-	if currentStatus.errored > 0 {
+	if hasRunFailed() {
+		os.Exit(1)
+	}
+}
+
+func applySampleFile(path string, keyColumn string) {
+	if path == "" {
+		return
+	}
+
+	if completeConfig.respondentKeyTemplate != nil {
+		kingpin.FatalUsage("--respondent-key cannot be combined with --sample.")
+	}
+
+	sample, err := readSampleFile(path, keyColumn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if completeConfig.target == 0 {
+		completeConfig.target = len(sample)
+	} else if completeConfig.target > len(sample) {
+		kingpin.FatalUsage("Sample file '%s' has only %d rows; cannot complete %d interviews.", path, len(sample), completeConfig.target)
+	}
+
+	completeConfig.sample = sample
+}
+
+func applyStateFile(path string, erroredOnly bool) {
+	if path == "" {
+		if erroredOnly {
+			kingpin.FatalUsage("--errored-only requires --state-file.")
+		}
+		return
+	}
+
+	state, err := openStateFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
+
+	numbers := []int{}
+	for i := 0; i < completeConfig.target; i++ {
+		if state.shouldRun(i, erroredOnly) {
+			numbers = append(numbers, i)
+		}
+	}
+
+	if len(numbers) == 0 {
+		printInfo("Nothing to do; all interviews are finished according to \"%s\".", path)
+		os.Exit(0)
+	}
+
+	completeConfig.skipped = completeConfig.target - len(numbers)
+	completeConfig.target = len(numbers)
+	completeConfig.interviewNumbers = numbers
+	completeConfig.stateFile = state
 }
 
 func ensureConsistentCompleteOptions() {
@@ -150,36 +350,25 @@ func ensureConsistentCompleteOptions() {
 	}
 }
 
-func getGolangFormat(cmdLineFormat string) string {
-	resultFormat := ""
-	previousCharacter := '\x00'
-	percentCount := 0
-	numberFormat := "%%0%dd"
-
-	for _, character := range cmdLineFormat {
-		if character == '%' {
-			if percentCount == 0 || previousCharacter == '%' {
-				percentCount++
-			} else {
-				kingpin.FatalUsage("Invalid format string '%s'. Percentage signs must not be separated by anything.", cmdLineFormat)
-			}
-		} else {
-			if previousCharacter == '%' {
-				resultFormat += fmt.Sprintf(numberFormat, percentCount)
-			}
-			resultFormat += string(character)
-		}
-
-		previousCharacter = character
+func getPercentage(flag string, value int) int {
+	if value < 0 || value > 100 {
+		kingpin.FatalUsage("--%s must be a percentage between 0 and 100.", flag)
 	}
 
-	if previousCharacter == '%' {
-		resultFormat += fmt.Sprintf(numberFormat, percentCount)
-	}
+	return value
+}
 
-	if percentCount == 0 && cmdLineFormat != "" {
-		kingpin.FatalUsage("Invalid format string '%s'. Must have at least one percentage sign.", cmdLineFormat)
+// getCommandInterviewURL returns the interview url given to command, or an
+// empty string for commands without one.
+func getCommandInterviewURL(command string) string {
+	switch command {
+	case recordCommand.FullCommand():
+		return *recordInterviewURLArg
+	case completeCommand.FullCommand():
+		return *completeInterviewURLArg
+	case "replay":
+		return *replayInterviewURLArg
 	}
 
-	return resultFormat
+	return ""
 }
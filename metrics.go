@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const metricsPrefix = "complete_interviews_"
+
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (hist *histogram) observe(value float64) {
+	for i, bound := range latencyBuckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.sum += value
+	hist.count++
+}
+
+type latencyKey struct {
+	method   string
+	pageType string
+}
+
+// runMetrics keeps counters and histograms of all interviews and serves
+// them in the Prometheus text format.
+type runMetrics struct {
+	lock sync.Mutex
+
+	started          uint64
+	finished         map[string]uint64
+	validationErrors uint64
+	latency          map[latencyKey]*histogram
+
+	// type of the page each running interview is answering
+	pageTypes map[int]string
+}
+
+func newRunMetrics() *runMetrics {
+	return &runMetrics{
+		finished:  make(map[string]uint64),
+		latency:   make(map[latencyKey]*histogram),
+		pageTypes: make(map[int]string),
+	}
+}
+
+func (metrics *runMetrics) handleEvent(event interviewEvent) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+
+	switch event.Event {
+	case eventInterviewStarted:
+		metrics.started++
+		metrics.pageTypes[event.Interview] = "start"
+	case eventQuestionDetected:
+		metrics.pageTypes[event.Interview] = event.QuestionType
+	case eventPageFetched:
+		key := latencyKey{method: event.Method, pageType: metrics.pageTypes[event.Interview]}
+		hist, ok := metrics.latency[key]
+		if !ok {
+			hist = &histogram{counts: make([]uint64, len(latencyBuckets))}
+			metrics.latency[key] = hist
+		}
+		hist.observe(event.duration.Seconds())
+	case eventValidationError:
+		metrics.validationErrors++
+	case eventInterviewCompleted, eventInterviewAbandoned, eventInterviewErrored:
+		status := strings.TrimPrefix(event.Event, "interview-")
+		metrics.finished[status]++
+		delete(metrics.pageTypes, event.Interview)
+	}
+}
+
+func (metrics *runMetrics) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.write(response)
+}
+
+func (metrics *runMetrics) write(output io.Writer) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+
+	writeHeader := func(name string, kind string, help string) {
+		fmt.Fprintf(output, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, kind)
+	}
+
+	writeHeader("started_total", "counter", "Number of interviews started.")
+	fmt.Fprintf(output, "%sstarted_total %d\n", metricsPrefix, metrics.started)
+
+	writeHeader("finished_total", "counter", "Number of interviews finished, by status.")
+	for _, status := range []string{"completed", "abandoned", "errored"} {
+		fmt.Fprintf(output, "%sfinished_total{status=%q} %d\n", metricsPrefix, status, metrics.finished[status])
+	}
+
+	finished := metrics.finished["completed"] + metrics.finished["abandoned"] + metrics.finished["errored"]
+	writeHeader("active", "gauge", "Number of interviews in progress.")
+	fmt.Fprintf(output, "%sactive %d\n", metricsPrefix, metrics.started-finished)
+
+	var workers, retried int64
+	if currentStatus != nil {
+		workers = atomic.LoadInt64(&currentStatus.workers)
+		retried = atomic.LoadInt64(&currentStatus.retried)
+	}
+
+	writeHeader("workers", "gauge", "Number of running workers.")
+	fmt.Fprintf(output, "%sworkers %d\n", metricsPrefix, workers)
+
+	writeHeader("validation_errors_total", "counter", "Number of answers rejected by the interview.")
+	fmt.Fprintf(output, "%svalidation_errors_total %d\n", metricsPrefix, metrics.validationErrors)
+
+	writeHeader("http_retries_total", "counter", "Number of requests retried after a transient failure.")
+	fmt.Fprintf(output, "%shttp_retries_total %d\n", metricsPrefix, retried)
+
+	keys := []latencyKey{}
+	for key := range metrics.latency {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].pageType != keys[j].pageType {
+			return keys[i].pageType < keys[j].pageType
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	writeHeader("request_duration_seconds", "histogram", "Duration of requests, by method and type of the page answered.")
+	for _, key := range keys {
+		hist := metrics.latency[key]
+		labels := fmt.Sprintf("method=%q,page_type=%q", key.method, key.pageType)
+
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(output, "%srequest_duration_seconds_bucket{%s,le=\"%g\"} %d\n", metricsPrefix, labels, bound, hist.counts[i])
+		}
+		fmt.Fprintf(output, "%srequest_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", metricsPrefix, labels, hist.count)
+		fmt.Fprintf(output, "%srequest_duration_seconds_sum{%s} %g\n", metricsPrefix, labels, hist.sum)
+		fmt.Fprintf(output, "%srequest_duration_seconds_count{%s} %d\n", metricsPrefix, labels, hist.count)
+	}
+}
+
+// serveMetrics starts serving the metrics on addr in the background and
+// returns the address it listens on.
+func serveMetrics(addr string, metrics *runMetrics) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+
+	go func() {
+		err := http.Serve(listener, mux)
+		printVerbose("metrics", "Metrics server stopped: %v\n", err)
+	}()
+
+	return listener.Addr().String(), nil
+}
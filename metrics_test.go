@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+	currentStatus = &completeStatus{workers: 2, retried: 3}
+
+	metrics := newRunMetrics()
+	eventListeners = []eventListener{metrics.handleEvent}
+	defer func() { eventListeners = nil }()
+
+	pageURL := "http://localhost/interview"
+	body := "<html></html>"
+
+	for i := 0; i < 3; i++ {
+		tracker := newInterviewTracker(i, "")
+		tracker.start()
+		tracker.fetch("GET", &pageURL, func() (pageContent, error) {
+			time.Sleep(time.Millisecond)
+			return pageContent{body: &body, url: &pageURL}, nil
+		})
+		tracker.questionDetected(pageInfo{questionType: qTypeNumber})
+		tracker.fetch("POST", &pageURL, func() (pageContent, error) {
+			return pageContent{body: &body, url: &pageURL}, nil
+		})
+
+		if i == 0 {
+			tracker.answerRejected(errAnswerRejected)
+			tracker.finish(errAnswerRejected)
+		} else if i == 1 {
+			tracker.finish(nil)
+		}
+	}
+
+	addr, err := serveMetrics("127.0.0.1:0", metrics)
+	assert.NoError(err)
+
+	response, err := http.Get("http://" + addr + "/metrics")
+	assert.NoError(err)
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	assert.NoError(err)
+	text := string(content)
+
+	assert.Contains(text, "complete_interviews_started_total 3\n")
+	assert.Contains(text, "complete_interviews_finished_total{status=\"completed\"} 1\n")
+	assert.Contains(text, "complete_interviews_finished_total{status=\"errored\"} 1\n")
+	assert.Contains(text, "complete_interviews_active 1\n")
+	assert.Contains(text, "complete_interviews_workers 2\n")
+	assert.Contains(text, "complete_interviews_validation_errors_total 1\n")
+	assert.Contains(text, "complete_interviews_http_retries_total 3\n")
+	assert.Contains(text, "complete_interviews_request_duration_seconds_count{method=\"GET\",page_type=\"start\"} 3\n")
+	assert.Contains(text, "complete_interviews_request_duration_seconds_bucket{method=\"POST\",page_type=\"Number\",le=\"+Inf\"} 3\n")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/html"
+)
+
+// chance (in percent) that an abandoned interview without specific breakoff
+// questions stops at any given question page
+const randomBreakoffChance = 20
+
+// maxNavigationPerPage is how often a button is pressed on the same page of
+// an interview, so even --back 100 gets to the end eventually
+const maxNavigationPerPage = 1
+
+const (
+	navigationAnswer  = ""
+	navigationAbandon = "abandon"
+	navigationBack    = "button-back"
+	navigationClear   = "button-clear"
+)
+
+type interviewNavigation struct {
+	abandon          bool
+	breakoffQuestion string
+
+	// pressed counts the buttons pressed per page (button and questions)
+	pressed map[string]int
+}
+
+func newInterviewNavigation() interviewNavigation {
+	result := interviewNavigation{pressed: map[string]int{}}
+
+	if completeConfig.breakoffPercentage > 0 && random.Intn(100) < completeConfig.breakoffPercentage {
+		result.abandon = true
+
+		if len(completeConfig.breakoffQuestions) > 0 {
+			result.breakoffQuestion = completeConfig.breakoffQuestions[random.Intn(len(completeConfig.breakoffQuestions))]
+		}
+	}
+
+	return result
+}
+
+func (navigation interviewNavigation) isActive() bool {
+	return navigation.abandon || completeConfig.backPercentage > 0 || completeConfig.clearPercentage > 0
+}
+
+// nextAction decides whether to answer the page, abandon the interview or
+// press one of the other navigation buttons. For buttons, it also returns
+// the form to post.
+func (navigation interviewNavigation) nextAction(document *string) (string, url.Values, error) {
+	doc, err := html.Parse(strings.NewReader(*document))
+
+	if err != nil {
+		return navigationAnswer, nil, err
+	}
+
+	questions := getQuestionIDs(doc)
+	buttons := getNavigationButtons(doc)
+
+	if navigation.abandon && len(questions) > 0 {
+		if navigation.breakoffQuestion != "" {
+			if arrayContains(questions, navigation.breakoffQuestion) {
+				return navigationAbandon, nil, nil
+			}
+		} else if random.Intn(100) < randomBreakoffChance {
+			return navigationAbandon, nil, nil
+		}
+	}
+
+	for _, button := range []string{navigationBack, navigationClear} {
+		percentage := completeConfig.backPercentage
+		if button == navigationClear {
+			percentage = completeConfig.clearPercentage
+		}
+
+		page := button + " " + strings.Join(questions, " ")
+		if navigation.pressed[page] >= maxNavigationPerPage {
+			continue
+		}
+
+		if percentage > 0 && arrayContains(buttons, button) && random.Intn(100) < percentage {
+			navigation.pressed[page]++
+			result, err := getNavigationValues(doc, button)
+			return button, result, err
+		}
+	}
+
+	return navigationAnswer, nil, nil
+}
+
+func markInterviewAbandoned() {
+	atomic.AddInt64(&currentStatus.abandoned, 1)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestGetQuestionIDs(t *testing.T) {
+	assert := assert.New(t)
+
+	forAllQuestionTypes(t, func(doc *html.Node, questionType string) {
+		questions := getQuestionIDs(doc)
+
+		if questionType == qTypePage {
+			assert.Empty(questions)
+		} else {
+			assert.Equal([]string{"q1"}, questions)
+		}
+	})
+}
+
+func TestGetNavigationValues(t *testing.T) {
+	assert := assert.New(t)
+
+	forBothTemplates(t, "number", func(doc *html.Node) {
+		assert.Contains(getNavigationButtons(doc), "button-back")
+
+		values, err := getNavigationValues(doc, "button-back")
+		assert.NoError(err)
+
+		result := flattenURLValues(values)
+
+		assert.NotEmpty(result["button-back"])
+		assert.NotContains(result, "button-next")
+		assert.Equal("032794ea-dfbb-4c33-95c2-2fbe5befd885", result["screenId"])
+	})
+}
+
+func TestAbandonInterviewAtQuestion(t *testing.T) {
+	numberOfRequests := 0
+	setupMocking(t, "pages/test-interview", &numberOfRequests)
+
+	completeConfig.breakoffPercentage = 100
+	completeConfig.breakoffQuestions = []string{"q90"}
+
+	assert := assert.New(t)
+
+	err := performInterview(http.Client{}, &completeConfig.interviewURL, newInterviewTracker(0, ""))
+	assert.NoError(err)
+
+	assert.Equal(11, numberOfRequests)
+	assert.Equal(int64(1), currentStatus.abandoned)
+
+	currentStatus.completed = 1
+	assert.Equal(0, getSuccessfulCount(), "abandoned interviews are not successful")
+}
+
+func TestNavigationPressesButtonsOncePerPage(t *testing.T) {
+	assert := assert.New(t)
+
+	completeConfig = &completeConfiguration{backPercentage: 100, clearPercentage: 100}
+	navigation := newInterviewNavigation()
+
+	stringForBothTemplates(t, "number", func(page string) {
+		action, values, err := navigation.nextAction(&page)
+		assert.NoError(err)
+		assert.Equal(navigationBack, action)
+		assert.NotEmpty(values.Get(navigationBack))
+
+		action, _, err = navigation.nextAction(&page)
+		assert.NoError(err)
+		assert.Equal(navigationClear, action)
+
+		action, values, err = navigation.nextAction(&page)
+		assert.NoError(err)
+		assert.Equal(navigationAnswer, action, "the page is answered after its buttons were pressed")
+		assert.Nil(values)
+
+		// the next interview presses them again
+		navigation = newInterviewNavigation()
+	})
+}
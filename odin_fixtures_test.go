@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"complete-interviews/fixtures"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// generateFixture returns the page for a single ODIN question definition.
+func generateFixture(t *testing.T, definition string, template string) string {
+	questions, err := fixtures.ParseScript(definition, template)
+	assert.NoError(t, err)
+	assert.Len(t, questions, 1)
+
+	page, err := fixtures.Render(questions[0])
+	assert.NoError(t, err)
+
+	return page
+}
+
+func TestGeneratedFixturesMatchTestInterview(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	script, err := ioutil.ReadFile("test-script.odin")
+	assert.NoError(err)
+
+	questions, err := fixtures.ParseScript(string(script), "default")
+	assert.NoError(err)
+	assert.Len(questions, 12)
+
+	for i, question := range questions {
+		generated, err := fixtures.Render(question)
+		assert.NoError(err)
+
+		captured, err := getHTMLString(filepath.Join("pages", "test-interview", fmt.Sprintf("page%d.html", i+1)))
+		assert.NoError(err)
+
+		generatedDoc, err := htmlStringToNode(generated)
+		assert.NoError(err)
+		capturedDoc, err := htmlStringToNode(captured)
+		assert.NoError(err)
+
+		t.Logf("Comparing %s (%s) with page %d", question.ID, question.Template, i+1)
+
+		assert.Equal(getQuestionType(capturedDoc), getQuestionType(generatedDoc))
+		assert.Equal(getQuestionIDs(capturedDoc), getQuestionIDs(generatedDoc))
+		assert.Equal(getPageTitle(capturedDoc), getPageTitle(generatedDoc))
+
+		capturedAnswers, _, err := getInterviewResponse(&captured, "")
+		assert.NoError(err)
+		generatedAnswers, _, err := getInterviewResponse(&generated, "")
+		assert.NoError(err)
+
+		assert.Equal(len(capturedAnswers), len(generatedAnswers))
+		assert.Equal(len(capturedAnswers["answer-"+question.ID+"-m"]), len(generatedAnswers["answer-"+question.ID+"-m"]))
+	}
+}
+
+func TestGeneratedFixtureVariants(t *testing.T) {
+	globalConfig = &globalConfiguration{}
+
+	manyCodes := []string{}
+	for i := 1; i <= 20; i++ {
+		manyCodes = append(manyCodes, fmt.Sprintf("%02d:Answer %d", i, i))
+	}
+
+	tests := []struct {
+		definition   string
+		questionType string
+		check        func(*assert.Assertions, url.Values)
+	}{
+		{
+			definition:   "*QUESTION 5 *CODES 10L20 *MULTI *MIN 3 *MAX 3\nPick three\n\n" + strings.Join(manyCodes, "\n"),
+			questionType: qTypeCategory,
+			check: func(assert *assert.Assertions, answers url.Values) {
+				assert.Len(answers["answer-q5-m"], 3)
+			},
+		},
+		{
+			definition:   "*QUESTION 4 *CODES 10L1 *MULTI\nPick any\n\n1:Yes\n2:No",
+			questionType: qTypeCategory,
+			check: func(assert *assert.Assertions, answers url.Values) {
+				assert.Len(answers["answer-q4-m"], 1)
+			},
+		},
+		{
+			definition:   "*QUESTION 6 *CODES 10L1\nPick one\n\n1:Yes\n2:No",
+			questionType: qTypeCategory,
+			check: func(assert *assert.Assertions, answers url.Values) {
+				assert.Len(answers["answer-q6-m"], 1)
+				assert.Contains([]string{"q6-1", "q6-2"}, answers.Get("answer-q6"))
+			},
+		},
+		{
+			definition:   "*QUESTION 7 *NUMBER 20L3 *MIN [100] *MAX [100]\nHow many?",
+			questionType: qTypeNumber,
+			check: func(assert *assert.Assertions, answers url.Values) {
+				assert.Equal("100", answers.Get("answer-q7"))
+			},
+		},
+		{
+			definition:   "*QUESTION 8 *NUMBER 20L1\nHow many?",
+			questionType: qTypeNumber,
+			check: func(assert *assert.Assertions, answers url.Values) {
+				value, err := strconv.Atoi(answers.Get("answer-q8"))
+				assert.NoError(err)
+				assert.True(value >= 0 && value <= 9)
+			},
+		},
+		{
+			definition:   "*QUESTION 9 *ALPHA 30L3\nName?",
+			questionType: qTypeOpenSingle,
+			check: func(assert *assert.Assertions, answers url.Values) {
+				assert.True(len(answers.Get("answer-q9")) <= 3)
+			},
+		},
+		{
+			definition:   "*QUESTION 11 *OPEN 40L1 *MULTI\nTell us more",
+			questionType: qTypeOpenMulti,
+			check: func(assert *assert.Assertions, answers url.Values) {
+				assert.NotEmpty(answers.Get("answer-q11"))
+			},
+		},
+	}
+
+	for _, template := range templates {
+		for _, test := range tests {
+			assert := assert.New(t)
+
+			page := generateFixture(t, test.definition, template)
+			doc, err := htmlStringToNode(page)
+			assert.NoError(err)
+
+			answers, info, err := getInterviewResponse(&page, "")
+			assert.NoError(err)
+			assert.Equal(test.questionType, info.questionType, test.definition)
+			assert.Equal(test.questionType, getQuestionType(doc))
+			assert.Len(info.questions, 1)
+
+			test.check(assert, answers)
+		}
+	}
+}
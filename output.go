@@ -1,9 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"os"
@@ -11,22 +14,115 @@ import (
 	tm "github.com/buger/goterm"
 )
 
+const (
+	outputModeAuto  = "auto"
+	outputModeTTY   = "tty"
+	outputModePlain = "plain"
+	outputModeJSON  = "json"
+	outputModeQuiet = "quiet"
+)
+
+// getOutputMode resolves the auto output mode: the live display only makes
+// sense on a terminal, and verbose lines would garble it.
+func getOutputMode(mode string, isTerminal bool, verbose bool) string {
+	if mode != outputModeAuto {
+		return mode
+	}
+
+	if isTerminal && !verbose {
+		return outputModeTTY
+	}
+
+	return outputModePlain
+}
+
+func isTTYOutput() bool {
+	return globalConfig.outputMode == outputModeTTY
+}
+
+type progressEvent struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	Command    string    `json:"command,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Target     int       `json:"target"`
+	Completed  int       `json:"completed"`
+	Successful int       `json:"successful"`
+	Errored    int       `json:"errored"`
+	Active     int       `json:"active"`
+	Abandoned  int64     `json:"abandoned"`
+	Retried    int64     `json:"retried"`
+	Kind       string    `json:"kind,omitempty"`
+	Message    string    `json:"message,omitempty"`
+
+	Errors []errorSummaryEvent `json:"errors,omitempty"`
+}
+
+type errorSummaryEvent struct {
+	Kind    string `json:"kind"`
+	Count   int    `json:"count"`
+	Example string `json:"example"`
+}
+
+var jsonOutputLock sync.Mutex
+
+func printJSON(event progressEvent) {
+	jsonOutputLock.Lock()
+	defer jsonOutputLock.Unlock()
+
+	event.Time = time.Now()
+	if currentStatus != nil {
+		event.Target = completeConfig.target
+		event.Completed = currentStatus.completed
+		event.Successful = getSuccessfulCount()
+		event.Errored = currentStatus.errored
+		event.Active = currentStatus.active
+		event.Abandoned = atomic.LoadInt64(&currentStatus.abandoned)
+		event.Retried = atomic.LoadInt64(&currentStatus.retried)
+	}
+
+	bytes, _ := json.Marshal(event)
+	fmt.Println(string(bytes))
+}
+
+// printInfo prints a message that is not part of the progress display.
+func printInfo(format string, args ...interface{}) {
+	switch globalConfig.outputMode {
+	case outputModeJSON:
+		printJSON(progressEvent{Event: "info", Message: fmt.Sprintf(format, args...)})
+	case outputModeQuiet:
+	default:
+		fmt.Printf(format+"\n", args...)
+	}
+}
+
 func printError(err error) {
-	if !globalConfig.verboseOutput && isCompletingInterviews() {
-		errorChannel <- err
+	queue := isTTYOutput() && isCompletingInterviews()
+	collectedErrors.add(err, queue)
+
+	if queue {
+		return
+	}
+
+	if globalConfig.outputMode == outputModeJSON && isCompletingInterviews() {
+		printJSON(progressEvent{Event: "error", Kind: classifyError(err), Message: err.Error()})
 	} else {
 		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 	}
 }
 
+// printVerbose prints debug output to stderr, so it does not mix with the
+// progress output on stdout.
 func printVerbose(context string, format string, args ...interface{}) {
 	if globalConfig.verboseOutput {
-		fmt.Printf("VERBOSE: ["+context+"] "+format, args...)
+		fmt.Fprintf(os.Stderr, "VERBOSE: ["+context+"] "+format, args...)
 	}
 }
 
 func printFirstMessage() {
-	if isCompletingInterviews() {
+	if globalConfig.outputMode == outputModeJSON {
+		printJSON(progressEvent{Event: "start", Command: globalConfig.command})
+	} else if globalConfig.outputMode != outputModeQuiet && isCompletingInterviews() {
 		lines := []string{}
 
 		whatAreWeDoing := "interview"
@@ -48,117 +144,252 @@ func printFirstMessage() {
 			lines = addLine(lines, "Using replay file \"%s\"", completeConfig.replayFile.Name())
 		}
 
+		if completeConfig.respondentKeyTemplate != nil {
+			lines = addLine(lines, "Run id \"%s\"; first respondent key \"%s\".",
+				completeConfig.respondentKeyTemplate.runID, getRespondentKey(0))
+		}
+
+		if completeConfig.sample != nil {
+			lines = addLine(lines, "Using %d of %d rows from the sample file.", completeConfig.target+completeConfig.skipped, len(completeConfig.sample))
+		}
+
+		if completeConfig.stateFile != nil {
+			lines = addLine(lines, "Keeping track of progress in \"%s\" (skipped %d already finished).",
+				completeConfig.stateFile.file.Name(), completeConfig.skipped)
+		}
+
 		if completeConfig.waitBetweenPosts > 0 {
 			lines = addLine(lines, "Waiting %s between questions.", completeConfig.waitBetweenPosts.String())
 		}
 
+		if completeConfig.breakoffPercentage > 0 {
+			lines = addLine(lines, "Abandoning %d%% of interviews.", completeConfig.breakoffPercentage)
+		}
+
 		flushLines(lines)
 	}
 }
 
 func printFinalMessage(reason string) {
-	if isCompletingInterviews() {
-		lines := []string{}
-
-		for len(errorChannel) > 0 {
-			err := <-errorChannel
-			width := tm.Width()
-			emptyLine := strings.Repeat(" ", width)
+	if globalConfig.outputMode == outputModeQuiet {
+		return
+	}
 
-			line := fmt.Sprintf("%v", err)
-			length := len([]rune(line))
-			if length < width {
-				line += strings.Repeat(" ", width-length)
-			}
-			tm.Printf("ERROR: %s\n%s\n", line, emptyLine)
+	if globalConfig.outputMode == outputModeJSON && isCompletingInterviews() {
+		event := progressEvent{Event: "finished", Reason: reason}
+		for _, group := range collectedErrors.summary() {
+			event.Errors = append(event.Errors, errorSummaryEvent{Kind: group.kind, Count: group.count, Example: group.example.Error()})
 		}
+		printJSON(event)
+	} else if isCompletingInterviews() {
+		lines := []string{}
+
+		// errors that were not shown yet are part of the summary below
+		collectedErrors.takePending()
 
 		addBasicStatusLines(&lines)
+		addErrorSummaryLines(&lines)
 
-		whatAreWeDoing := "interviews"
+		lines = addLine(lines, "")
+		lines = addLine(lines, "%s Completed %d of %d %s.", reason, currentStatus.completed, completeConfig.target, getWhatAreWeDoing())
 
-		if globalConfig.command == "replay" {
-			whatAreWeDoing = "replay playthroughs"
+		if currentStatus.errored > 0 && globalConfig.artefactsDir != "" {
+			lines = addLine(lines, "Pages of failed interviews are saved in \"%s\".", globalConfig.artefactsDir)
 		}
 
-		lines = addLine(lines, strings.Repeat(" ", tm.Width()))
-		lines = addLine(lines, "%s Completed %d of %d %s.", reason, currentStatus.completed, completeConfig.target, whatAreWeDoing)
-
 		flushLines(lines)
 	} else if globalConfig.command == "record" {
 		fmt.Printf("%s\n", reason)
 	}
 }
 
+// printOutputError reports a file of the run that could not be written.
+// The interviews are fine, but the run fails because its results are
+// incomplete.
+func printOutputError(err error) {
+	atomic.AddInt64(&currentStatus.outputErrors, 1)
+	printError(err)
+}
+
+// hasRunFailed tells if the run should exit with an error.
+func hasRunFailed() bool {
+	return currentStatus.errored > 0 || atomic.LoadInt64(&currentStatus.outputErrors) > 0
+}
+
+// getSuccessfulCount returns the number of interviews that were completed,
+// so neither errored nor abandoned on purpose.
+func getSuccessfulCount() int {
+	return currentStatus.completed - currentStatus.errored - int(atomic.LoadInt64(&currentStatus.abandoned))
+}
+
 func addBasicStatusLines(lines *[]string) {
-	if !globalConfig.verboseOutput {
-		*lines = addLine(*lines, "Successful : %4d", currentStatus.completed-currentStatus.errored)
+	if isTTYOutput() {
+		*lines = addLine(*lines, "Successful : %4d", getSuccessfulCount())
 		*lines = addLine(*lines, "Error      : %4d", currentStatus.errored)
 
 		if currentStatus.active > 0 {
 			*lines = addLine(*lines, "Active     : %4d", currentStatus.active)
 		}
+		if abandoned := atomic.LoadInt64(&currentStatus.abandoned); abandoned > 0 {
+			*lines = addLine(*lines, "Abandoned  : %4d", abandoned)
+		}
+		if retried := atomic.LoadInt64(&currentStatus.retried); retried > 0 {
+			*lines = addLine(*lines, "Retried    : %4d", retried)
+		}
 	} else {
-		*lines = addLine(*lines, "Successful: %4d, Error: %4d",
-			currentStatus.completed-currentStatus.errored, currentStatus.errored)
+		*lines = addLine(*lines, "Successful: %4d, Error: %4d, Abandoned: %4d, Retried: %4d",
+			getSuccessfulCount(), currentStatus.errored,
+			atomic.LoadInt64(&currentStatus.abandoned), atomic.LoadInt64(&currentStatus.retried))
+	}
+}
+
+func addErrorSummaryLines(lines *[]string) {
+	groups := collectedErrors.summary()
+
+	if len(groups) == 0 {
+		return
+	}
+
+	*lines = addLine(*lines, "")
+	*lines = addLine(*lines, "Errors by type:")
+
+	for _, group := range groups {
+		*lines = addLine(*lines, "  %-22s: %4d  e.g. %v", group.kind, group.count, group.example)
 	}
 }
 
 func startOutputLoop() {
+	switch globalConfig.outputMode {
+	case outputModeTTY:
+		startTTYOutputLoop()
+	case outputModePlain, outputModeJSON:
+		startPeriodicOutputLoop()
+	}
+}
+
+func startTTYOutputLoop() {
 	spinner := []rune(`⠁⠁⠉⠙⠚⠒⠂⠂⠒⠲⠴⠤⠄⠄⠤⠠⠠⠤⠦⠖⠒⠐⠐⠒⠓⠋⠉⠈⠈`)
 	frameIndex := 0
+	lastWidth := tm.Width()
 
 	lines := []string{}
 	for currentStatus.completed < completeConfig.target {
-		select {
-		case err := <-errorChannel:
-			// some error happened
-			emptyLine := strings.Repeat(" ", tm.Width())
-			tm.Printf("ERROR: %v\n%s\n", err, emptyLine)
-		default:
-			// no errors yet
+		currentLiveStatus.addErrors(collectedErrors.takePending())
+
+		if width := tm.Width(); width != lastWidth {
+			// lines written at the old width may have wrapped, so the
+			// cursor can't be moved back reliably; start from a clean screen
+			tm.Clear()
+			tm.MoveCursor(1, 1)
+			tm.Flush()
+			lastWidth = width
 		}
 
 		s := currentStatus
 		percentDone := s.completed * 100 / completeConfig.target
+		now := time.Now()
 
-		if !globalConfig.verboseOutput {
-			whatAreWeDoing := "interviews"
+		statusLine := fmt.Sprintf("%d of %d %s (%d%%)",
+			s.completed,
+			completeConfig.target,
+			getWhatAreWeDoing(),
+			percentDone)
+		progressBar := getProgressBar(tm.Width() - 1)
 
-			if globalConfig.command == "replay" {
-				whatAreWeDoing = "replay playthroughs"
-			}
+		lines = addLine(lines, "[%s] %s", string(spinner[frameIndex]), statusLine)
+		lines = addLine(lines, "")
+		lines = addLine(lines, progressBar)
+		lines = addLine(lines, "")
+
+		addBasicStatusLines(&lines)
+
+		requestRate, interviewRate := currentLiveStatus.rates(now)
+		lines = addLine(lines, "Throughput : %.1f requests/s, %.1f %s/min", requestRate, interviewRate, getWhatAreWeDoing())
+
+		if eta, ok := currentLiveStatus.eta(now, s.completed, completeConfig.target); ok {
+			lines = addLine(lines, "ETA        : %s", eta.Round(time.Second))
+		}
+
+		lines = addLine(lines, "")
+
+		workerLines := currentLiveStatus.workerLines()
+		errorLines := currentLiveStatus.errorLines()
 
-			statusLine := fmt.Sprintf("%d of %d %s (%d%%)",
-				s.completed,
-				completeConfig.target,
-				whatAreWeDoing,
-				percentDone)
-			progressBar := getProgressBar(tm.Width() - 1)
+		// keep the display within the terminal, or moving the cursor up
+		// won't get back to the first line
+		available := tm.Height() - len(lines) - 2
+		if len(errorLines) > 0 {
+			available -= len(errorLines) + 2
+		}
+		if available < 1 {
+			available = 1
+		}
+		if len(workerLines) > available {
+			hidden := len(workerLines) - available + 1
+			workerLines = append(workerLines[:available-1], fmt.Sprintf("... and %d more workers", hidden))
+		}
 
-			lines = addLine(lines, "[%s] %s", string(spinner[frameIndex]), statusLine)
+		for _, line := range workerLines {
+			lines = addLine(lines, "%s", line)
+		}
+
+		if len(errorLines) > 0 {
 			lines = addLine(lines, "")
-			lines = addLine(lines, progressBar)
-			lines = addLine(lines, strings.Repeat(" ", tm.Width()))
+			lines = addLine(lines, "Recent errors:")
+			for _, line := range errorLines {
+				lines = addLine(lines, "  %s", line)
+			}
 		}
 
-		addBasicStatusLines(&lines)
+		// blank out whatever is left of a longer previous frame
+		for len(lines) < s.lastLinesWritten {
+			lines = addLine(lines, "")
+		}
 
 		flushLines(lines)
 
-		if !globalConfig.verboseOutput {
-			tm.MoveCursorUp(len(lines) + 1)
-			s.lastLinesWritten = len(lines)
-			frameIndex = (frameIndex + 1) % len(spinner)
-			time.Sleep(50 * time.Millisecond)
-		} else {
-			time.Sleep(4 * time.Second)
-		}
+		tm.MoveCursorUp(len(lines) + 1)
+		s.lastLinesWritten = len(lines)
+		frameIndex = (frameIndex + 1) % len(spinner)
+		time.Sleep(50 * time.Millisecond)
 
 		lines = lines[:0]
 	}
 }
 
+// startPeriodicOutputLoop prints the progress every progress interval, as
+// a line of text or as JSON.
+func startPeriodicOutputLoop() {
+	for currentStatus.completed < completeConfig.target {
+		time.Sleep(globalConfig.progressInterval)
+
+		if currentStatus.completed >= completeConfig.target {
+			return
+		}
+
+		if globalConfig.outputMode == outputModeJSON {
+			printJSON(progressEvent{Event: "progress"})
+			continue
+		}
+
+		s := currentStatus
+		lines := []string{}
+		lines = addLine(lines, "%d of %d %s (%d%%)", s.completed, completeConfig.target,
+			getWhatAreWeDoing(), s.completed*100/completeConfig.target)
+		addBasicStatusLines(&lines)
+
+		fmt.Println(strings.Join(lines, " - "))
+	}
+}
+
+func getWhatAreWeDoing() string {
+	if globalConfig.command == "replay" {
+		return "replay playthroughs"
+	}
+
+	return "interviews"
+}
+
 func getProgressBar(size int) string {
 	s := currentStatus
 
@@ -173,8 +404,11 @@ func addLine(lines []string, format string, arguments ...interface{}) []string {
 
 	length := len([]rune(line))
 
-	if length < tm.Width() {
+	if isTTYOutput() && length < tm.Width() {
 		line += strings.Repeat(" ", tm.Width()-length)
+	} else if isTTYOutput() && length > tm.Width() && tm.Width() > 1 {
+		// wrapped lines would break moving the cursor back up
+		line = string([]rune(line)[:tm.Width()-1]) + "…"
 	}
 
 	return append(lines, line)
@@ -182,20 +416,20 @@ func addLine(lines []string, format string, arguments ...interface{}) []string {
 
 func flushLines(lines []string) {
 	for _, line := range lines {
-		if !globalConfig.verboseOutput {
+		if isTTYOutput() {
 			tm.Println(line)
 		} else {
 			fmt.Println(line)
 		}
 	}
 
-	if !globalConfig.verboseOutput {
+	if isTTYOutput() {
 		tm.Flush()
 	}
 }
 
 func clearScreen() {
-	if !globalConfig.verboseOutput && isCompletingInterviews() {
+	if isTTYOutput() && isCompletingInterviews() {
 		lines := []string{}
 		for i := 0; i < currentStatus.lastLinesWritten; i++ {
 			lines = append(lines, strings.Repeat(" ", tm.Width()))
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOutputMode(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(outputModeTTY, getOutputMode(outputModeAuto, true, false))
+	assert.Equal(outputModePlain, getOutputMode(outputModeAuto, false, false))
+	assert.Equal(outputModePlain, getOutputMode(outputModeAuto, true, true))
+	assert.Equal(outputModeJSON, getOutputMode(outputModeJSON, true, false))
+	assert.Equal(outputModeQuiet, getOutputMode(outputModeQuiet, false, true))
+	assert.Equal(outputModeTTY, getOutputMode(outputModeTTY, false, false))
+}
+
+func TestPrintVerboseWritesToStderr(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{verboseOutput: true, outputMode: outputModeJSON}
+
+	stdoutReader, stdoutWriter, _ := os.Pipe()
+	stderrReader, stderrWriter, _ := os.Pipe()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdoutWriter, stderrWriter
+
+	printVerbose("test", "some %s\n", "detail")
+
+	os.Stdout, os.Stderr = stdout, stderr
+	stdoutWriter.Close()
+	stderrWriter.Close()
+
+	written, _ := ioutil.ReadAll(stdoutReader)
+	assert.Empty(string(written))
+
+	written, _ = ioutil.ReadAll(stderrReader)
+	assert.Equal("VERBOSE: [test] some detail\n", string(written))
+}
@@ -3,43 +3,148 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/net/html"
 )
 
-func startProxyForInterview() {
-	requests := 0
+func startProxyForInterview() error {
+	var lock sync.Mutex
+	completed := 0
 	isDone := false
 
+	// every tester (proxy session) records to a replay file of its own
+	recordings := map[string]*recordingSession{}
+	replayFiles := []*os.File{}
+	defer func() {
+		for _, file := range replayFiles {
+			// the file from the command line is closed by the caller
+			if file != recordConfig.replayFile {
+				file.Close()
+			}
+		}
+	}()
+
+	// getRecording returns the recording of the session that made the
+	// request. A session gets a replay file when it posts its first answers;
+	// the first one uses the file from the command line.
+	getRecording := func(request *http.Request, posting bool) (*recordingSession, error) {
+		session := getProxySession(request)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		recording, ok := recordings[session.id]
+		if !ok {
+			recording = newRecordingSession(nil)
+			recording.overlay = recordConfig.overlay
+			recordings[session.id] = recording
+		}
+
+		if posting && recording.output == nil {
+			// recordings are numbered in the order they start posting,
+			// which can differ from the order of the sessions
+			number := len(replayFiles) + 1
+			file := recordConfig.replayFile
+			if number > 1 {
+				var err error
+				file, err = os.Create(getSessionReplayFileName(recordConfig.replayFile.Name(), number))
+				if err != nil {
+					return nil, err
+				}
+			}
+			replayFiles = append(replayFiles, file)
+			recording.output = file
+
+			if err := writeReplayHeader(file); err != nil {
+				return nil, err
+			}
+
+			fmt.Printf("Recording session %d to \"%s\"\n", number, file.Name())
+		}
+
+		return recording, nil
+	}
+
 	handleRequest := func(request *http.Request) {
-		if request.Method == "POST" {
-			request.ParseForm()
-			form := request.Form
+		if request.Method != "POST" {
+			return
+		}
+
+		recording, err := getRecording(request, true)
+		if err != nil {
+			printError(err)
+			return
+		}
+
+		recording.record(request)
+	}
 
-			printVerbose("recording", "Recording interview answer %v\n", form)
-			writeResponseToFile(recordConfig.replayFile, form)
+	handleResponse := func(response *http.Response) error {
+		recording, err := getRecording(response.Request, false)
+		if err != nil {
+			return err
 		}
+
+		return recording.pageServed(response)
 	}
 
-	redirectAtEndOfInterview := func(response http.ResponseWriter, request *http.Request) {
-		if strings.Contains(request.URL.String(), endOfInterviewPath) {
-			if requests < (recordConfig.target - 1) {
-				requests++
-				fmt.Printf("Completed interview %d of %d\n", requests, recordConfig.target)
-				http.Redirect(response, request, "/", 302)
-			} else {
-				isDone = true
-			}
+	handleControl := func(response http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != annotationPath {
+			http.NotFound(response, request)
+			return
+		}
+
+		recording, err := getRecording(request, false)
+		if err != nil {
+			http.Error(response, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		recording.handleAnnotation(response, request)
+	}
+
+	redirectAtEndOfInterview := func(response http.ResponseWriter, request *http.Request) bool {
+		if request.Method != "GET" || !strings.Contains(request.URL.String(), endOfInterviewPath) {
+			return false
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if isDone {
+			return false
+		}
+
+		completed++
+		fmt.Printf("Completed interview %d of %d (session %d)\n", completed, recordConfig.target, getProxySession(request).number)
+
+		if completed < recordConfig.target {
+			http.Redirect(response, request, "/", http.StatusFound)
+			return true
 		}
+
+		isDone = true
+		return false
 	}
 
 	isLastRequest := func(url string) bool {
+		lock.Lock()
+		defer lock.Unlock()
+
 		willStop := strings.Contains(url, endOfInterviewPath) && isDone
 
 		if willStop {
@@ -48,136 +153,442 @@ func startProxyForInterview() {
 		return willStop
 	}
 
-	runProxy(recordConfig.interviewURL, handleRequest, redirectAtEndOfInterview, isLastRequest)
+	options := proxyOptions{
+		listenAddr:    recordConfig.listenAddr,
+		externalHosts: recordConfig.externalHosts,
+		har:           recordConfig.har,
+
+		handleResponse: handleResponse,
+		handleControl:  handleControl,
+		onListening: func(url string) {
+			fmt.Printf("Serving on %s\n", url)
+
+			if recordConfig.openBrowser {
+				openURLInBrowser(url)
+			}
+		},
+	}
+
+	err := runProxy(recordConfig.interviewURL, options, handleRequest, redirectAtEndOfInterview, isLastRequest)
+	if err != nil {
+		return err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	fmt.Printf("All interview(s) are completed.\n")
+	for _, file := range replayFiles {
+		fmt.Printf("Recording written to \"%s\".\n", file.Name())
+	}
+	return nil
+}
+
+// getSessionReplayFileName returns the name of the replay file of the nth
+// session, e.g. interview-2.replay.
+func getSessionReplayFileName(path string, number int) string {
+	if number <= 1 {
+		return path
+	}
+
+	extension := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, extension), number, extension)
+}
+
+// recordingSession writes the answers posted by the browser to the replay
+// file, together with the context of the page they were posted on.
+type recordingSession struct {
+	lock   sync.Mutex
+	output io.Writer
+
+	lastPage       replayStep
+	lastPageServed time.Time
+
+	// annotations are the tags for the current page, see annotate
+	annotations replayStep
+	overlay     bool
+}
+
+func newRecordingSession(output io.Writer) *recordingSession {
+	return &recordingSession{output: output, lastPageServed: time.Now()}
+}
+
+func (session *recordingSession) pageServed(response *http.Response) error {
+	if !strings.Contains(response.Header.Get("Content-Type"), "text/html") {
+		return nil
+	}
+
+	body, err := readResponseBody(response)
+	if err != nil {
+		return err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	session.lastPage = replayStep{
+		title:        getPageTitle(doc),
+		questionType: getQuestionType(doc),
+		questions:    getQuestionIDs(doc),
+	}
+	session.lastPageServed = time.Now()
+
+	if !session.overlay {
+		return nil
+	}
+
+	body, err = injectAnnotationOverlay(body, session.lastPage.questions, describeAnnotations(session.annotations))
+	if err != nil {
+		return err
+	}
+
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	response.ContentLength = int64(len(body))
+	response.Header.Set("Content-Length", strconv.Itoa(len(body)))
 
-	fmt.Printf("All interview(s) are completed. Recording written to \"%s\".\n", recordConfig.replayFile.Name())
+	return nil
+}
+
+func (session *recordingSession) record(request *http.Request) {
+	if request.Method != "POST" {
+		return
+	}
+
+	request.ParseForm()
+
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	step := session.lastPage
+	step.answers = request.PostForm
+	step.thinkTime = time.Since(session.lastPageServed)
+	step.randomize = session.annotations.randomize
+	step.fromSample = session.annotations.fromSample
+	session.annotations = replayStep{}
+
+	printVerbose("recording", "Recording interview answer %v\n", step.answers)
+	if err := writeReplayStep(session.output, step); err != nil {
+		printError(err)
+	}
+}
+
+type proxyOptions struct {
+	listenAddr    string
+	onListening   func(url string)
+	externalHosts []string
+	har           *harLog
+
+	// handleResponse is called for every response of the interview host,
+	// before it is passed to the browser.
+	handleResponse func(*http.Response) error
+
+	// handleControl serves the requests under controlPathPrefix, which are
+	// not forwarded.
+	handleControl func(http.ResponseWriter, *http.Request)
+}
+
+// controlPathPrefix is the path on the proxy for requests to the proxy
+// itself, e.g. from the overlay in recorded pages.
+const controlPathPrefix = "/_recording/"
+
+// listenForProxy starts listening on listenAddr (port 0 picks a free port)
+// and returns the url to open in the browser.
+func listenForProxy(listenAddr string) (net.Listener, string, error) {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return nil, "", err
+	}
+
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	return listener, "http://" + net.JoinHostPort(host, port), nil
 }
 
 func runProxy(
 	firstURL string,
+	options proxyOptions,
 	handleRequest func(*http.Request),
-	redirectIfNeeded func(http.ResponseWriter, *http.Request),
-	shouldCloseServer func(url string) bool) {
+	redirectIfNeeded func(http.ResponseWriter, *http.Request) bool,
+	shouldCloseServer func(url string) bool) error {
 	var pendingRequestWaitGroup sync.WaitGroup
 	var serverWaitGroup sync.WaitGroup
+	var closeServer sync.Once
 
-	client := http.Client{
-		Timeout: globalConfig.requestTimeout,
+	startURL, err := url.Parse(firstURL)
+	if err != nil {
+		return err
 	}
 
-	var remoteHost string
+	sessions := newProxySessions(startURL, options.externalHosts)
+	reverseProxy := newReverseProxy(options)
 
-	http.HandleFunc("/", func(response http.ResponseWriter, request *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(response http.ResponseWriter, request *http.Request) {
 		pendingRequestWaitGroup.Add(1)
 		defer pendingRequestWaitGroup.Done()
 
-		if request.URL.Path == "/" && request.Method == "GET" {
-			// first request, so load the first URL and redirect
-			httpResp, err := client.Get(firstURL)
-
-			if err != nil {
-				printError(err)
-				return
-			}
+		session := sessions.get(response, request)
+		request = withProxySession(request, session)
 
-			http.Redirect(response, request, httpResp.Request.URL.Path, 302)
-
-			remoteURL, _ := url.Parse(httpResp.Request.URL.String())
-			remoteURL.Path = "/"
-			remoteHost = remoteURL.String()
+		if request.URL.Path == "/" && request.Method == "GET" {
+			// first request, so start the interview on the remote host
+			session.rewriter.remote.set(startURL)
+			http.Redirect(response, request, startURL.RequestURI(), http.StatusFound)
 			return
 		}
 
-		handleRequest(request)
-
-		remoteRequestURL, _ := url.Parse(remoteHost)
-		remoteRequestURL.Path = request.URL.Path
-
-		printVerbose("proxy", "INCOMING %s request on path %s\n", request.Method, request.URL.Path)
-		printVerbose("proxy", "OUTGOING %s request to url %s\n", request.Method, remoteRequestURL)
-
-		var httpResp *http.Response
-		var err error
-
-		switch request.Method {
-		case "GET":
-			httpResp, err = client.Get(remoteRequestURL.String())
+		if strings.HasPrefix(request.URL.Path, controlPathPrefix) && options.handleControl != nil {
+			options.handleControl(response, request)
+			return
+		}
 
-			redirectIfNeeded(response, request)
-		case "POST":
-			request.ParseForm()
-			form := request.Form
-			httpResp, err = client.PostForm(remoteRequestURL.String(), form)
+		if strings.HasPrefix(request.URL.Path, externalPathPrefix) {
+			externalRequest, ok := session.rewriter.withExternalTarget(request)
+			if !ok {
+				http.Error(response, "unknown external host", http.StatusForbidden)
+				return
+			}
 
-			http.Redirect(response, request, httpResp.Request.URL.Path, 302)
+			// requests to other hosts are not part of the recording
+			reverseProxy.ServeHTTP(response, externalRequest)
+			return
 		}
 
-		if err != nil {
+		if err := bufferRequestBody(request); err != nil {
 			printError(err)
+			http.Error(response, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		headers := response.Header()
+		handleRequest(request)
 
-		for key, valList := range httpResp.Header {
-			for _, val := range valList {
-				headers.Add(key, val)
-			}
+		// handleRequest may have read the body (e.g. with ParseForm), so
+		// forward a fresh copy
+		if request.GetBody != nil {
+			request.Body, _ = request.GetBody()
 		}
 
-		// we don't want to cache anything!
-		headers.Del("Cache-Control")
+		printVerbose("proxy", "INCOMING %s request on %s\n", request.Method, request.URL.RequestURI())
+
+		if redirectIfNeeded(response, request) {
+			return
+		}
 
-		response.Write(getBytesForHTTPResponse(*httpResp))
+		reverseProxy.ServeHTTP(response, request)
 
 		if shouldCloseServer(request.URL.String()) {
 			go func() {
 				time.Sleep(500 * time.Millisecond)
-				serverWaitGroup.Done()
+				closeServer.Do(serverWaitGroup.Done)
 			}()
 		}
 	})
+
+	listener, url, err := listenForProxy(options.listenAddr)
+	if err != nil {
+		return err
+	}
+
 	server := &http.Server{
-		Addr: ":4222",
+		Handler: mux,
 	}
 
 	defer server.Close()
 
 	serverWaitGroup.Add(1)
 
-	url := "http://localhost" + server.Addr
-	fmt.Printf("Serving on %s\n", url)
-
 	go func() {
-		server.ListenAndServe()
+		server.Serve(listener)
 	}()
 
-	openURLInBrowser(url)
+	if options.onListening != nil {
+		options.onListening(url)
+	}
 
 	printVerbose("proxy", "Waiting for interview to finish...\n")
 	serverWaitGroup.Wait()
 	printVerbose("proxy", "Waiting for pending requests to finish...\n")
 	pendingRequestWaitGroup.Wait()
 	printVerbose("proxy", "Done, killing server now.\n")
+
+	return nil
 }
 
-func writeResponseToFile(outputFile *os.File, form url.Values) {
-	for key, value := range form {
-		if key != "screenId" {
-			outputFile.WriteString(fmt.Sprintf("%s=%v\n", key, value))
+// remoteHost is the scheme and host requests are forwarded to. It changes
+// when the interview redirects to another host.
+type remoteHost struct {
+	lock sync.Mutex
+	url  *url.URL
+}
+
+func (remote *remoteHost) get() *url.URL {
+	remote.lock.Lock()
+	defer remote.lock.Unlock()
+
+	result := *remote.url
+	return &result
+}
+
+func (remote *remoteHost) set(value *url.URL) {
+	remote.lock.Lock()
+	defer remote.lock.Unlock()
+
+	remote.url = &url.URL{Scheme: value.Scheme, Host: value.Host}
+}
+
+func newReverseProxy(options proxyOptions) *httputil.ReverseProxy {
+	transport := newHTTPClient(nil).Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if options.har != nil {
+		transport = options.har.transport(transport)
+	}
+
+	return &httputil.ReverseProxy{
+		Transport: transport,
+		Director: func(request *http.Request) {
+			remote := getProxySession(request).rewriter.remote.get()
+			target := getExternalTarget(request)
+			if target == nil {
+				target = remote
+			}
+
+			request.URL.Scheme = target.Scheme
+			request.URL.Host = target.Host
+			request.Host = target.Host
+
+			// the interview may check these against its own host
+			for _, header := range []string{"Origin", "Referer"} {
+				if value := request.Header.Get(header); value != "" {
+					if headerURL, err := url.Parse(value); err == nil {
+						headerURL.Scheme = remote.Scheme
+						headerURL.Host = remote.Host
+						request.Header.Set(header, headerURL.String())
+					}
+				}
+			}
+
+			// let the transport decompress, so pages can be rewritten
+			request.Header.Del("Accept-Encoding")
+			removeProxySessionCookie(request)
+
+			printVerbose("proxy", "OUTGOING %s request to url %s\n", request.Method, request.URL)
+		},
+		ModifyResponse: func(response *http.Response) error {
+			rewriter := getProxySession(response.Request).rewriter
+
+			rewriteLocation(response, rewriter)
+			rewriteCookies(response)
+
+			// we don't want to cache anything!
+			response.Header.Set("Cache-Control", "no-store")
+
+			if options.handleResponse != nil && getExternalTarget(response.Request) == nil {
+				if err := options.handleResponse(response); err != nil {
+					return err
+				}
+			}
+
+			return rewriter.rewriteContent(response)
+		},
+		ErrorHandler: func(response http.ResponseWriter, request *http.Request, err error) {
+			printError(err)
+			http.Error(response, err.Error(), http.StatusBadGateway)
+		},
+	}
+}
+
+// rewriteLocation makes redirects point to the proxy. A redirect of the
+// interview to another host switches the proxy over to that host.
+func rewriteLocation(response *http.Response, rewriter *urlRewriter) {
+	location := response.Header.Get("Location")
+	if location == "" {
+		return
+	}
+
+	target, err := response.Request.URL.Parse(location)
+	if err != nil {
+		return
+	}
+
+	if getExternalTarget(response.Request) == nil && target.Host != response.Request.URL.Host {
+		printVerbose("proxy", "Following redirect to %s\n", target.Host)
+		rewriter.remote.set(target)
+	}
+
+	if localURL, ok := rewriter.localURL(target); ok {
+		response.Header.Set("Location", localURL)
+	}
+}
+
+// rewriteCookies makes cookies of the remote host acceptable for the
+// proxy, which runs on another host and without TLS.
+func rewriteCookies(response *http.Response) {
+	cookies := response.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+
+	response.Header.Del("Set-Cookie")
+
+	for _, cookie := range cookies {
+		cookie.Domain = ""
+		cookie.Secure = false
+		if cookie.SameSite == http.SameSiteNoneMode {
+			cookie.SameSite = http.SameSiteLaxMode
 		}
+
+		response.Header.Add("Set-Cookie", cookie.String())
+	}
+}
+
+// bufferRequestBody reads the body, so it can be inspected by the recording
+// and still be forwarded.
+func bufferRequestBody(request *http.Request) error {
+	if request.Body == nil {
+		return nil
 	}
 
-	outputFile.WriteString("---\n")
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return nil
 }
 
-func getBytesForHTTPResponse(response http.Response) []byte {
-	defer response.Body.Close()
+// readResponseBody reads the body and puts it back, so the response can
+// still be passed on.
+func readResponseBody(response *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(response.Body)
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
 
-	return buf.Bytes()
+	return body, nil
 }
 
 func openURLInBrowser(url string) {
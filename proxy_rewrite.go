@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// externalPathPrefix is the path on the proxy under which other hosts are
+// served, e.g. /_external/https/cdn.example.com/style.css.
+const externalPathPrefix = "/_external/"
+
+type externalTargetKey struct{}
+
+// absoluteURLPattern matches the start of an absolute (or protocol
+// relative) url in a link attribute or a CSS url(). Other strings, like
+// xmlns and DTD uris, are not links and are left alone.
+var absoluteURLPattern = regexp.MustCompile(
+	`(?i)((?:\b(?:href|src|action|formaction|poster)\s*=\s*["']?)|(?:url\(\s*["']?))((?:https?:)?//)([A-Za-z0-9.-]+(?::\d+)?)/?`)
+
+// urlRewriter points urls of the interview host in proxied pages to the
+// proxy, so the browser does not bypass it. Other hosts are only proxied
+// when the interview redirects to them, and hosts in externalHosts never.
+type urlRewriter struct {
+	remote        *remoteHost
+	externalHosts []string
+
+	// issued are the other hosts (scheme://host) the rewriter sent the
+	// browser to; only those are served under externalPathPrefix
+	lock   sync.Mutex
+	issued map[string]bool
+}
+
+func (rewriter *urlRewriter) isExternalHost(host string) bool {
+	hostname := host
+	if index := strings.LastIndex(host, ":"); index >= 0 {
+		hostname = host[:index]
+	}
+
+	for _, external := range rewriter.externalHosts {
+		if strings.EqualFold(external, host) || strings.EqualFold(external, hostname) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// localURL returns the url on the proxy for target, or false when the
+// browser should load it directly.
+func (rewriter *urlRewriter) localURL(target *url.URL) (string, bool) {
+	if rewriter.isExternalHost(target.Host) {
+		return "", false
+	}
+
+	if strings.EqualFold(target.Host, rewriter.remote.get().Host) {
+		return target.RequestURI(), true
+	}
+
+	rewriter.lock.Lock()
+	defer rewriter.lock.Unlock()
+
+	if rewriter.issued == nil {
+		rewriter.issued = map[string]bool{}
+	}
+	rewriter.issued[target.Scheme+"://"+strings.ToLower(target.Host)] = true
+
+	return externalPathPrefix + target.Scheme + "/" + target.Host + target.RequestURI(), true
+}
+
+func (rewriter *urlRewriter) hasIssued(target *url.URL) bool {
+	rewriter.lock.Lock()
+	defer rewriter.lock.Unlock()
+
+	return rewriter.issued[target.Scheme+"://"+strings.ToLower(target.Host)]
+}
+
+func (rewriter *urlRewriter) rewriteBody(body []byte) []byte {
+	remote := rewriter.remote.get()
+
+	return absoluteURLPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		parts := absoluteURLPattern.FindSubmatch(match)
+		prefix, host := string(parts[1]), string(parts[3])
+
+		if !strings.EqualFold(host, remote.Host) || rewriter.isExternalHost(host) {
+			return match
+		}
+
+		return []byte(prefix + "/")
+	})
+}
+
+// rewriteContent rewrites the urls in HTML and CSS responses.
+func (rewriter *urlRewriter) rewriteContent(response *http.Response) error {
+	contentType := response.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "text/html") && !strings.Contains(contentType, "text/css") {
+		return nil
+	}
+
+	if response.Header.Get("Content-Encoding") != "" {
+		printVerbose("proxy", "Not rewriting encoded response from %s\n", response.Request.URL)
+		return nil
+	}
+
+	body, err := readResponseBody(response)
+	if err != nil {
+		return err
+	}
+
+	body = rewriter.rewriteBody(body)
+
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	response.ContentLength = int64(len(body))
+	response.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return nil
+}
+
+// withExternalTarget turns a request for /_external/scheme/host/path into a
+// request for /path, to be forwarded to scheme://host. Hosts the rewriter
+// did not send the browser to are refused, so the proxy cannot be used to
+// reach arbitrary hosts.
+func (rewriter *urlRewriter) withExternalTarget(request *http.Request) (*http.Request, bool) {
+	if !strings.HasPrefix(request.URL.Path, externalPathPrefix) {
+		return request, false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(request.URL.Path, externalPathPrefix), "/", 3)
+	if len(parts) < 2 || (parts[0] != "http" && parts[0] != "https") || parts[1] == "" {
+		return request, false
+	}
+
+	target := &url.URL{Scheme: parts[0], Host: parts[1]}
+	if !rewriter.hasIssued(target) {
+		return request, false
+	}
+
+	request = request.WithContext(context.WithValue(request.Context(), externalTargetKey{}, target))
+	request.URL.Path = "/"
+	if len(parts) == 3 {
+		request.URL.Path += parts[2]
+	}
+	request.URL.RawPath = ""
+
+	return request, true
+}
+
+func getExternalTarget(request *http.Request) *url.URL {
+	target, _ := request.Context().Value(externalTargetKey{}).(*url.URL)
+	return target
+}
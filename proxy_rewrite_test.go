@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRewriter(externalHosts ...string) *urlRewriter {
+	remote, _ := url.Parse("https://interview.example.com")
+	return &urlRewriter{remote: &remoteHost{url: remote}, externalHosts: externalHosts}
+}
+
+func TestRewriteBody(t *testing.T) {
+	assert := assert.New(t)
+
+	rewriter := newTestRewriter()
+
+	cases := map[string]string{
+		`<form action="https://interview.example.com/Interview/Post">`: `<form action="/Interview/Post">`,
+		`<a href='http://interview.example.com'>`:                      `<a href='/'>`,
+		`<img SRC=//interview.example.com/img/logo.png>`:               `<img SRC=/img/logo.png>`,
+		`background: url(https://interview.example.com/img/bg.png);`:   `background: url(/img/bg.png);`,
+		`background: url( "https://interview.example.com/bg.png");`:    `background: url( "/bg.png");`,
+		`<link href="https://az683115.vo.msecnd.net/css/site.css">`:    `<link href="https://az683115.vo.msecnd.net/css/site.css">`,
+		`<script src="//cdn.example.com/app.js">`:                      `<script src="//cdn.example.com/app.js">`,
+		`<html xmlns="http://interview.example.com/ns">`:               `<html xmlns="http://interview.example.com/ns">`,
+		`<!DOCTYPE html SYSTEM "https://interview.example.com/x.dtd">`: `<!DOCTYPE html SYSTEM "https://interview.example.com/x.dtd">`,
+		`var next = "https://interview.example.com/next";`:             `var next = "https://interview.example.com/next";`,
+		`<a href="/relative/path">`:                                    `<a href="/relative/path">`,
+		`// just a comment`:                                            `// just a comment`,
+	}
+
+	for input, expected := range cases {
+		assert.Equal(expected, string(rewriter.rewriteBody([]byte(input))), input)
+	}
+
+	assert.Empty(rewriter.issued)
+}
+
+func TestLocalURL(t *testing.T) {
+	assert := assert.New(t)
+
+	rewriter := newTestRewriter("fonts.example.com")
+
+	target, _ := url.Parse("https://interview.example.com/page?x=1")
+	local, ok := rewriter.localURL(target)
+	assert.True(ok)
+	assert.Equal("/page?x=1", local)
+
+	target, _ = url.Parse("http://cdn.example.com:8080/app.js")
+	local, ok = rewriter.localURL(target)
+	assert.True(ok)
+	assert.Equal("/_external/http/cdn.example.com:8080/app.js", local)
+
+	target, _ = url.Parse("https://fonts.example.com/font.css")
+	_, ok = rewriter.localURL(target)
+	assert.False(ok)
+}
+
+func TestWithExternalTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	rewriter := newTestRewriter()
+
+	request, _ := http.NewRequest("GET", "http://localhost:4222/_external/https/cdn.example.com/css/site.css?v=2", nil)
+	_, ok := rewriter.withExternalTarget(request)
+	assert.False(ok, "hosts the proxy did not link to are refused")
+
+	target, _ := url.Parse("https://cdn.example.com/css/site.css")
+	rewriter.localURL(target)
+
+	request, ok = rewriter.withExternalTarget(request)
+	assert.True(ok)
+	assert.Equal("/css/site.css", request.URL.Path)
+	assert.Equal("v=2", request.URL.RawQuery)
+	assert.Equal("https://cdn.example.com", getExternalTarget(request).String())
+
+	request, _ = http.NewRequest("GET", "http://localhost:4222/_external/http/cdn.example.com/x", nil)
+	_, ok = rewriter.withExternalTarget(request)
+	assert.False(ok, "the scheme is part of the host that was linked to")
+
+	request, _ = http.NewRequest("GET", "http://localhost:4222/Interview/Page", nil)
+	request, ok = rewriter.withExternalTarget(request)
+	assert.False(ok)
+	assert.Nil(getExternalTarget(request))
+}
+
+func TestRewriteContentOnlyRewritesHTMLAndCSS(t *testing.T) {
+	assert := assert.New(t)
+
+	rewriter := newTestRewriter()
+	body := `<a href="https://interview.example.com/next">`
+
+	for contentType, expected := range map[string]string{
+		"text/html; charset=utf-8": `<a href="/next">`,
+		"text/css":                 `<a href="/next">`,
+		"application/json":         body,
+	} {
+		response := &http.Response{
+			Header: http.Header{"Content-Type": {contentType}},
+			Body:   ioutil.NopCloser(strings.NewReader(body)),
+		}
+
+		assert.NoError(rewriter.rewriteContent(response))
+
+		result, _ := ioutil.ReadAll(response.Body)
+		assert.Equal(expected, string(result), contentType)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// proxySessionCookie identifies the browser of a tester, so several
+// testers can use the proxy at the same time. It is not sent to the
+// interview.
+const proxySessionCookie = "complete-interviews-session"
+
+type proxySessionKey struct{}
+
+// proxySession is the state of the proxy for one browser.
+type proxySession struct {
+	id       string
+	number   int
+	rewriter *urlRewriter
+}
+
+type proxySessions struct {
+	lock          sync.Mutex
+	sessions      map[string]*proxySession
+	startURL      *url.URL
+	externalHosts []string
+}
+
+func newProxySessions(startURL *url.URL, externalHosts []string) *proxySessions {
+	return &proxySessions{
+		sessions:      map[string]*proxySession{},
+		startURL:      startURL,
+		externalHosts: externalHosts,
+	}
+}
+
+// get returns the session of the browser that made the request. A browser
+// without a session gets a new one.
+func (sessions *proxySessions) get(response http.ResponseWriter, request *http.Request) *proxySession {
+	sessions.lock.Lock()
+	defer sessions.lock.Unlock()
+
+	if cookie, err := request.Cookie(proxySessionCookie); err == nil {
+		if session, ok := sessions.sessions[cookie.Value]; ok {
+			return session
+		}
+	}
+
+	id := newRunID()
+	for sessions.sessions[id] != nil {
+		id = newRunID()
+	}
+
+	remote := &remoteHost{url: &url.URL{Scheme: sessions.startURL.Scheme, Host: sessions.startURL.Host}}
+	session := &proxySession{
+		id:       id,
+		number:   len(sessions.sessions) + 1,
+		rewriter: &urlRewriter{remote: remote, externalHosts: sessions.externalHosts},
+	}
+	sessions.sessions[id] = session
+
+	http.SetCookie(response, &http.Cookie{Name: proxySessionCookie, Value: id, Path: "/", HttpOnly: true})
+	printVerbose("proxy", "Started session %d\n", session.number)
+
+	return session
+}
+
+func withProxySession(request *http.Request, session *proxySession) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), proxySessionKey{}, session))
+}
+
+func getProxySession(request *http.Request) *proxySession {
+	session, _ := request.Context().Value(proxySessionKey{}).(*proxySession)
+	return session
+}
+
+// removeProxySessionCookie keeps the cookie of the proxy away from the
+// interview.
+func removeProxySessionCookie(request *http.Request) {
+	cookies := request.Cookies()
+	request.Header.Del("Cookie")
+
+	kept := []string{}
+	for _, cookie := range cookies {
+		if cookie.Name != proxySessionCookie {
+			kept = append(kept, cookie.String())
+		}
+	}
+
+	if len(kept) > 0 {
+		request.Header.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
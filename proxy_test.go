@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenForProxyOnFreePort(t *testing.T) {
+	assert := assert.New(t)
+
+	listener, url, err := listenForProxy(":0")
+	assert.NoError(err)
+	defer listener.Close()
+
+	assert.True(strings.HasPrefix(url, "http://localhost:"), url)
+	assert.NotEqual("http://localhost:0", url)
+
+	other, otherURL, err := listenForProxy("127.0.0.1:0")
+	assert.NoError(err)
+	defer other.Close()
+
+	assert.True(strings.HasPrefix(otherURL, "http://127.0.0.1:"), otherURL)
+}
+
+func TestRunProxyMoreThanOnce(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{requestTimeout: 5 * time.Second}
+
+	remote := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/start" {
+			http.Redirect(response, request, "/interview", http.StatusFound)
+			return
+		}
+		response.Write([]byte("interview page"))
+	}))
+	defer remote.Close()
+
+	for i := 0; i < 2; i++ {
+		options := proxyOptions{
+			listenAddr: "127.0.0.1:0",
+			onListening: func(url string) {
+				go func() {
+					response, err := http.Get(url + "/")
+					assert.NoError(err)
+					defer response.Body.Close()
+
+					body, _ := ioutil.ReadAll(response.Body)
+					assert.Equal("interview page", string(body))
+				}()
+			},
+		}
+
+		err := runProxy(remote.URL+"/start", options,
+			func(*http.Request) {},
+			func(http.ResponseWriter, *http.Request) bool { return false },
+			func(string) bool { return true })
+		assert.NoError(err)
+	}
+}
+
+func TestRunProxyForwardsRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{requestTimeout: 5 * time.Second}
+
+	remote := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/start":
+			http.SetCookie(response, &http.Cookie{Name: "session", Value: "abc", Domain: "example.com", Secure: true})
+			http.Redirect(response, request, "/page?id=1", http.StatusFound)
+		case "/page":
+			cookie, err := request.Cookie("session")
+			assert.NoError(err)
+			assert.Equal("abc", cookie.Value)
+			assert.Equal("1", request.URL.Query().Get("id"))
+			assert.Equal("test", request.Header.Get("X-Test"))
+
+			response.Header().Set("Cache-Control", "max-age=3600")
+			response.Write([]byte("page"))
+		case "/post":
+			request.ParseForm()
+			assert.Equal("POST", request.Method)
+			assert.Equal("yes", request.PostForm.Get("answer-q1"))
+
+			response.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(response, request)
+		}
+	}))
+	defer remote.Close()
+
+	recorded := make(chan url.Values, 1)
+	options := proxyOptions{
+		listenAddr: "127.0.0.1:0",
+		onListening: func(proxyURL string) {
+			go func() {
+				jar, _ := cookiejar.New(nil)
+				client := http.Client{Jar: jar}
+
+				request, _ := http.NewRequest("GET", proxyURL+"/", nil)
+				request.Header.Set("X-Test", "test")
+				response, err := client.Do(request)
+				assert.NoError(err)
+				body, _ := ioutil.ReadAll(response.Body)
+				response.Body.Close()
+
+				assert.Equal("page", string(body))
+				assert.Equal("no-store", response.Header.Get("Cache-Control"))
+				assert.Equal(proxyURL+"/page?id=1", response.Request.URL.String())
+
+				response, err = client.PostForm(proxyURL+"/post", url.Values{"answer-q1": {"yes"}})
+				assert.NoError(err)
+				response.Body.Close()
+
+				assert.Equal(http.StatusAccepted, response.StatusCode)
+			}()
+		},
+	}
+
+	err := runProxy(remote.URL+"/start", options,
+		func(request *http.Request) {
+			if request.Method == "POST" {
+				request.ParseForm()
+				recorded <- request.PostForm
+			}
+		},
+		func(http.ResponseWriter, *http.Request) bool { return false },
+		func(url string) bool { return strings.Contains(url, "/post") })
+	assert.NoError(err)
+
+	assert.Equal("yes", (<-recorded).Get("answer-q1"))
+}
+
+func TestRewriteCookies(t *testing.T) {
+	assert := assert.New(t)
+
+	response := &http.Response{Header: http.Header{}}
+	response.Header.Add("Set-Cookie", "a=1; Domain=example.com; Secure; SameSite=None")
+	response.Header.Add("Set-Cookie", "b=2; Path=/; HttpOnly")
+
+	rewriteCookies(response)
+
+	cookies := response.Header["Set-Cookie"]
+	assert.Equal([]string{"a=1; SameSite=Lax", "b=2; Path=/; HttpOnly"}, cookies)
+}
+
+func TestRewriteLocation(t *testing.T) {
+	assert := assert.New(t)
+
+	start, _ := url.Parse("https://one.example.com/start")
+	rewriter := &urlRewriter{remote: &remoteHost{url: start}}
+
+	request, _ := http.NewRequest("GET", "https://one.example.com/start", nil)
+	response := &http.Response{Header: http.Header{}, Request: request}
+
+	response.Header.Set("Location", "/next?x=1")
+	rewriteLocation(response, rewriter)
+	assert.Equal("/next?x=1", response.Header.Get("Location"))
+	assert.Equal("one.example.com", rewriter.remote.get().Host)
+
+	response.Header.Set("Location", "https://two.example.com/other")
+	rewriteLocation(response, rewriter)
+	assert.Equal("/other", response.Header.Get("Location"))
+	assert.Equal("two.example.com", rewriter.remote.get().Host)
+}
+
+func TestRecordingSessionWritesPageContext(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	output := new(bytes.Buffer)
+	session := newRecordingSession(output)
+
+	page, err := getHTMLString("pages/default/single-coded.html")
+	assert.NoError(err)
+
+	response := &http.Response{
+		Header: http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:   ioutil.NopCloser(strings.NewReader(page)),
+	}
+	assert.NoError(session.pageServed(response))
+
+	// the page is still readable for the browser
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(page, string(body))
+
+	request := httptest.NewRequest("POST", "/Interview/Post", strings.NewReader("answer-q1=q1-2&screenId=abc"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	session.record(request)
+
+	steps := parseReplaySteps(output.String())
+	assert.Len(steps, 1)
+	assert.Equal("Nfield Web Interviewing Demo", steps[0].title)
+	assert.Equal(qTypeCategory, steps[0].questionType)
+	assert.Equal([]string{"q1"}, steps[0].questions)
+	assert.Equal(url.Values{"answer-q1": {"q1-2"}}, steps[0].answers)
+}
+
+func TestRecordSessionsSeparately(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{requestTimeout: 5 * time.Second}
+
+	remote := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Method == "POST" {
+			http.Redirect(response, request, endOfInterviewPath, http.StatusFound)
+			return
+		}
+
+		_, err := request.Cookie(proxySessionCookie)
+		assert.Error(err, "session cookie of the proxy is sent to the interview")
+
+		response.Header().Set("Content-Type", "text/html")
+		response.Write([]byte(`<html><body><div id="segment-q1"></div></body></html>`))
+	}))
+	defer remote.Close()
+
+	dir, err := ioutil.TempDir("", "record")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	file, err := os.Create(filepath.Join(dir, "interview.replay"))
+	assert.NoError(err)
+	defer file.Close()
+
+	listener, proxyURL, err := listenForProxy("127.0.0.1:0")
+	assert.NoError(err)
+	listener.Close()
+
+	recordConfig = &recordConfiguration{
+		target:       2,
+		interviewURL: remote.URL + "/start",
+		replayFile:   file,
+		listenAddr:   strings.TrimPrefix(proxyURL, "http://"),
+	}
+
+	stdout := os.Stdout
+	reader, writer, err := os.Pipe()
+	assert.NoError(err)
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan error)
+	go func() { done <- startProxyForInterview() }()
+
+	for i := 0; i < 50; i++ {
+		if response, err := http.Get(proxyURL + "/start"); err == nil {
+			response.Body.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for tester := 1; tester <= 2; tester++ {
+		jar, _ := cookiejar.New(nil)
+		client := http.Client{Jar: jar}
+
+		response, err := client.Get(proxyURL + "/")
+		assert.NoError(err)
+		response.Body.Close()
+
+		response, err = client.PostForm(proxyURL+"/start", url.Values{"answer-q1": {strconv.Itoa(tester)}})
+		assert.NoError(err)
+		response.Body.Close()
+	}
+
+	assert.NoError(<-done)
+	writer.Close()
+	os.Stdout = stdout
+
+	// the request that waits for the proxy is a session of its own, so the
+	// printed numbers must follow the replay files, not the sessions
+	printed, err := ioutil.ReadAll(reader)
+	assert.NoError(err)
+	assert.Contains(string(printed), fmt.Sprintf("Recording session 1 to \"%s\"", file.Name()))
+	assert.Contains(string(printed), fmt.Sprintf("Recording session 2 to \"%s\"", filepath.Join(dir, "interview-2.replay")))
+
+	for tester, name := range []string{"interview.replay", "interview-2.replay"} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(err)
+
+		steps := parseReplaySteps(string(content))
+		assert.Len(steps, 1, name)
+		assert.Equal(strconv.Itoa(tester+1), steps[0].answers.Get("answer-q1"), name)
+		assert.Equal([]string{"q1"}, steps[0].questions, name)
+	}
+}
+
+func TestGetSessionReplayFileName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("interview.replay", getSessionReplayFileName("interview.replay", 1))
+	assert.Equal("interview-2.replay", getSessionReplayFileName("interview.replay", 2))
+	assert.Equal(filepath.Join("dir", "rec-3"), getSessionReplayFileName(filepath.Join("dir", "rec"), 3))
+}
+
+func TestRunProxyForwardsPostBodyAfterRecording(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{requestTimeout: 5 * time.Second}
+
+	remote := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		request.ParseForm()
+		assert.Equal("yes", request.PostForm.Get("answer-q1"))
+
+		response.WriteHeader(http.StatusAccepted)
+	}))
+	defer remote.Close()
+
+	har := newHARLog()
+	options := proxyOptions{
+		listenAddr: "127.0.0.1:0",
+		har:        har,
+		onListening: func(proxyURL string) {
+			go func() {
+				// no GET first, so the POST goes out on a fresh connection
+				response, err := http.PostForm(proxyURL+"/post", url.Values{"answer-q1": {"yes"}})
+				assert.NoError(err)
+				response.Body.Close()
+
+				assert.Equal(http.StatusAccepted, response.StatusCode)
+			}()
+		},
+	}
+
+	err := runProxy(remote.URL+"/start", options,
+		func(request *http.Request) { request.ParseForm() },
+		func(http.ResponseWriter, *http.Request) bool { return false },
+		func(url string) bool { return strings.Contains(url, "/post") })
+	assert.NoError(err)
+
+	assert.Len(har.entries, 1)
+	assert.Equal("answer-q1=yes", har.entries[0].Request.PostData.Text)
+}
+
+func TestRunProxyRefusesUnknownExternalHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{requestTimeout: 5 * time.Second}
+
+	other := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		t.Errorf("request to other host was forwarded: %s", request.URL)
+	}))
+	defer other.Close()
+
+	otherURL, _ := url.Parse(other.URL)
+
+	options := proxyOptions{
+		listenAddr: "127.0.0.1:0",
+		onListening: func(proxyURL string) {
+			go func() {
+				response, err := http.Get(proxyURL + externalPathPrefix + "http/" + otherURL.Host + "/secret")
+				assert.NoError(err)
+				response.Body.Close()
+
+				assert.Equal(http.StatusForbidden, response.StatusCode)
+
+				// stop the proxy
+				response, err = http.Post(proxyURL+"/end", "text/plain", nil)
+				assert.NoError(err)
+				response.Body.Close()
+			}()
+		},
+	}
+
+	remote := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer remote.Close()
+
+	err := runProxy(remote.URL+"/start", options,
+		func(*http.Request) {},
+		func(http.ResponseWriter, *http.Request) bool { return false },
+		func(url string) bool { return strings.Contains(url, "/end") })
+	assert.NoError(err)
+}
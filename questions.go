@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -23,33 +24,41 @@ const (
 	qTypePage       = "Page"
 )
 
-func getInterviewResponse(document *string, previousHistoryOrder string) (url.Values, string, error) {
+type pageInfo struct {
+	historyOrder string
+	questionType string
+	questions    []string
+}
+
+var errAnswerRejected = &validationError{message: "validation error in interview (answer rejected)"}
+
+func getInterviewResponse(document *string, previousHistoryOrder string) (url.Values, pageInfo, error) {
+	page := pageInfo{}
 	doc, err := html.Parse(strings.NewReader(*document))
 
 	if err != nil {
-		return nil, "", err
+		return nil, page, &parseError{err: err}
 	}
 
 	result := url.Values{}
 	err = setCommonValues(doc, result)
 
 	if err != nil {
-		return nil, "", err
+		return nil, page, err
 	}
 
-	var historyOrder string
-
 	if val, ok := result["historyOrder"]; ok {
-		historyOrder = val[0]
+		page.historyOrder = val[0]
 
-		if historyOrder == previousHistoryOrder {
-			return nil, "", fmt.Errorf("validation error in interview (answer rejected)")
+		if page.historyOrder == previousHistoryOrder {
+			return nil, page, errAnswerRejected
 		}
 	}
 
-	questionType := getQuestionType(doc)
+	page.questionType = getQuestionType(doc)
+	page.questions = getQuestionIDs(doc)
 
-	switch questionType {
+	switch page.questionType {
 	case qTypeCategory:
 		err = setCategoryQuestionValues(doc, result)
 	case qTypeOpenMulti:
@@ -58,15 +67,25 @@ func getInterviewResponse(document *string, previousHistoryOrder string) (url.Va
 		err = setOpenSingleQuestionValues(doc, result)
 	case qTypeNumber:
 		err = setNumberQuestionValues(doc, result)
+	case qTypePage:
+		// a question we don't recognise would be posted without answers;
+		// a question without inputs (e.g. an info text) is just submitted
+		if len(page.questions) > 0 && hasAnswerInputs(doc) {
+			err = &unknownQuestionError{questions: page.questions}
+		}
 	}
 
 	if err != nil {
-		return nil, "", err
+		var unknownQuestionErr *unknownQuestionError
+		if !errors.As(err, &unknownQuestionErr) {
+			err = &parseError{err: err}
+		}
+		return nil, page, err
 	}
 
-	printVerbose("response", "Question type: %s, response: %v\n", questionType, result)
+	printVerbose("response", "Question type: %s, response: %v\n", page.questionType, result)
 
-	return result, historyOrder, nil
+	return result, page, nil
 }
 
 func getQuestionType(document *html.Node) string {
@@ -135,6 +154,89 @@ func setCommonValues(document *html.Node, result url.Values) error {
 	return nil
 }
 
+// getNavigationValues returns the form for pressing the given navigation
+// button (e.g. button-back) instead of answering the page.
+func getNavigationValues(document *html.Node, button string) (url.Values, error) {
+	result := url.Values{}
+	err := setCommonValues(document, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.Del("button-next")
+
+	walkDocumentByTag(document, "input", func(input *html.Node) {
+		attrs := attrsToMap(input.Attr)
+
+		if attrs["name"] == button {
+			result.Set(button, attrs["value"])
+		}
+	})
+
+	return result, nil
+}
+
+func getQuestionIDs(document *html.Node) []string {
+	segmentRegexp := regexp.MustCompile("^segment-(q\\d+)$")
+	result := []string{}
+
+	walkDocumentByTag(document, "div", func(node *html.Node) {
+		matched := segmentRegexp.FindStringSubmatch(attrsToMap(node.Attr)["id"])
+
+		if len(matched) > 0 {
+			result = append(result, matched[1])
+		}
+	})
+
+	return result
+}
+
+func hasAnswerInputs(document *html.Node) bool {
+	found := false
+
+	walkDocument(document, func(node *html.Node) {
+		if node.Type != html.ElementNode {
+			return
+		}
+
+		switch node.Data {
+		case "input", "select", "textarea":
+			if strings.HasPrefix(attrsToMap(node.Attr)["name"], "answer-") {
+				found = true
+			}
+		}
+	})
+
+	return found
+}
+
+func getPageTitle(document *html.Node) string {
+	title := ""
+
+	walkDocumentByTag(document, "title", func(node *html.Node) {
+		if title == "" && node.FirstChild != nil {
+			title = strings.Join(strings.Fields(node.FirstChild.Data), " ")
+		}
+	})
+
+	return title
+}
+
+func getNavigationButtons(document *html.Node) []string {
+	result := []string{}
+
+	walkDocumentByTag(document, "input", func(input *html.Node) {
+		attrs := attrsToMap(input.Attr)
+
+		if attrs["type"] == "submit" && strings.HasPrefix(attrs["name"], "button-") {
+			result = append(result, attrs["name"])
+		}
+	})
+
+	return result
+}
+
 func setOpenMultiQuestionValues(document *html.Node, result url.Values) error {
 	walkDocumentByTag(document, "textarea", func(node *html.Node) {
 		attrs := attrsToMap(node.Attr)
@@ -279,6 +381,10 @@ func setCategoryQuestionValues(document *html.Node, result url.Values) error {
 		}
 	})
 
+	if innerError != nil {
+		return innerError
+	}
+
 	if len(answerOptions) > 0 {
 		for i := 0; i < minChoices; i++ {
 			pickedAnswerIndex := random.Intn(len(answerOptions))
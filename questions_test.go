@@ -135,10 +135,10 @@ func TestGetInterviewResponseReturnsGoodResponseForMultiLineText(t *testing.T) {
 	assert := assert.New(t)
 
 	stringForBothTemplates(t, "open-multi", func(doc string) {
-		response, historyOrder, err := getInterviewResponse(&doc, "")
+		response, page, err := getInterviewResponse(&doc, "")
 		assert.NoError(err)
 
-		assert.Equal("0", historyOrder)
+		assert.Equal("0", page.historyOrder)
 
 		result := flattenURLValues(response)
 		t.Logf("%v\n", result)
@@ -152,10 +152,10 @@ func TestGetInterviewResponseReturnsGoodResponseForSingleCategory(t *testing.T)
 	assert := assert.New(t)
 
 	stringForBothTemplates(t, "single-coded", func(doc string) {
-		response, historyOrder, err := getInterviewResponse(&doc, "")
+		response, page, err := getInterviewResponse(&doc, "")
 		assert.NoError(err)
 
-		assert.Equal("0", historyOrder)
+		assert.Equal("0", page.historyOrder)
 
 		result := flattenURLValues(response)
 		t.Logf("%v\n", result)
@@ -172,10 +172,10 @@ func TestGetInterviewResponseReturnsGoodResponseForMultiCategory(t *testing.T) {
 	assert := assert.New(t)
 
 	stringForBothTemplates(t, "multi-coded", func(doc string) {
-		response, historyOrder, err := getInterviewResponse(&doc, "")
+		response, page, err := getInterviewResponse(&doc, "")
 		assert.NoError(err)
 
-		assert.Equal("0", historyOrder)
+		assert.Equal("0", page.historyOrder)
 
 		result := response["answer-q1-m"][0]
 		t.Logf("%v\n", result)
@@ -194,10 +194,10 @@ func TestGetInterviewResponseReturnsGoodResponseForSingleLineText(t *testing.T)
 	assert := assert.New(t)
 
 	stringForBothTemplates(t, "alpha-single", func(doc string) {
-		response, historyOrder, err := getInterviewResponse(&doc, "")
+		response, page, err := getInterviewResponse(&doc, "")
 		assert.NoError(err)
 
-		assert.Equal("0", historyOrder)
+		assert.Equal("0", page.historyOrder)
 
 		result := flattenURLValues(response)
 		t.Logf("%v\n", result)
@@ -211,10 +211,10 @@ func TestGetInterviewResponseReturnsGoodResponseForNumber(t *testing.T) {
 	assert := assert.New(t)
 
 	stringForBothTemplates(t, "number", func(doc string) {
-		response, historyOrder, err := getInterviewResponse(&doc, "")
+		response, page, err := getInterviewResponse(&doc, "")
 		assert.NoError(err)
 
-		assert.Equal("0", historyOrder)
+		assert.Equal("0", page.historyOrder)
 
 		result := flattenURLValues(response)
 		t.Logf("%v\n", result)
@@ -233,12 +233,48 @@ func TestGetInterviewResponseReturnsGoodResponseForWelcomePage(t *testing.T) {
 	assert := assert.New(t)
 
 	stringForBothTemplates(t, "welcome-page", func(doc string) {
-		response, historyOrder, err := getInterviewResponse(&doc, "")
+		response, page, err := getInterviewResponse(&doc, "")
 		assert.NoError(err)
 
 		result := flattenURLValues(response)
 
-		assert.Equal("0", historyOrder)
+		assert.Equal("0", page.historyOrder)
 		assert.Empty(result["answer-q1"])
 	})
 }
+
+func TestGetInterviewResponseSubmitsQuestionsWithoutInputs(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `<html><body><form>
+		<input id="historyOrder" name="historyOrder" type="hidden" value="0">
+		<div id="segment-q5"><p>Thank you for your answers so far.</p></div>
+	</form></body></html>`
+
+	response, page, err := getInterviewResponse(&doc, "")
+	assert.NoError(err)
+	assert.Equal(qTypePage, page.questionType)
+	assert.Equal([]string{"q5"}, page.questions)
+	assert.Equal("Next", response.Get("button-next"))
+
+	doc = `<html><body><form>
+		<input id="historyOrder" name="historyOrder" type="hidden" value="0">
+		<div id="segment-q5"><select name="answer-q5"><option value="1">One</option></select></div>
+	</form></body></html>`
+
+	_, _, err = getInterviewResponse(&doc, "")
+	var unknownQuestionErr *unknownQuestionError
+	assert.ErrorAs(err, &unknownQuestionErr)
+}
+
+func TestGetPageTitle(t *testing.T) {
+	assert := assert.New(t)
+
+	doc, err := getHTMLDocument("pages/default/welcome-page.html")
+	assert.NoError(err)
+	assert.Equal("Nfield Web Interviewing Demo", getPageTitle(doc))
+
+	doc, err = getHTMLDocument("pages/chicago/welcome-page.html")
+	assert.NoError(err)
+	assert.Equal("Template", getPageTitle(doc))
+}
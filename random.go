@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// lockedSource makes a rand.Source safe for concurrent use, so all workers
+// can share random.
+type lockedSource struct {
+	lock   sync.Mutex
+	source rand.Source64
+}
+
+func newLockedSource(seed int64) *lockedSource {
+	return &lockedSource{source: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (source *lockedSource) Int63() int64 {
+	source.lock.Lock()
+	defer source.lock.Unlock()
+
+	return source.source.Int63()
+}
+
+func (source *lockedSource) Uint64() uint64 {
+	source.lock.Lock()
+	defer source.lock.Unlock()
+
+	return source.source.Uint64()
+}
+
+func (source *lockedSource) Seed(seed int64) {
+	source.lock.Lock()
+	defer source.lock.Unlock()
+
+	source.source.Seed(seed)
+}
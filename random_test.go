@@ -0,0 +1,26 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomIsSafeForConcurrentUse(t *testing.T) {
+	assert := assert.New(t)
+
+	var wait sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := 0; i < 1000; i++ {
+				value := random.Intn(10)
+				assert.True(value >= 0 && value < 10)
+			}
+		}()
+	}
+
+	wait.Wait()
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replayStep is one posted page of a replay file. Besides the answers the
+// recording keeps some context of the page, as comment lines:
+//
+//	# think-time=4.2s
+//	# title=Question 1
+//	# question-type=Category
+//	# questions=q1 q2
+//	# random=q2
+//	# sample=q1:age
+//	answer-q1=[q1-2]
+//	---
+//
+// The random and sample lines are added by the tester while recording;
+// those questions are answered randomly or from a sample column on replay.
+type replayStep struct {
+	answers url.Values
+
+	thinkTime    time.Duration
+	title        string
+	questionType string
+	questions    []string
+
+	randomize  []string
+	fromSample map[string]string
+}
+
+const replayStepSeparator = "---\n"
+
+// Replay files start with a line with their format. Files without it are
+// from before the format had one: several values of a key were written on
+// a single line, like answer-q1-m=[2 5], and there was no page context.
+const (
+	replayFormatHeader  = "# replay-format="
+	replayFormatOld     = 1
+	replayFormatCurrent = 2
+)
+
+func writeReplayHeader(output io.Writer) error {
+	_, err := fmt.Fprintf(output, "%s%d\n", replayFormatHeader, replayFormatCurrent)
+	return err
+}
+
+// writeReplayFile writes a complete replay file in the current format.
+func writeReplayFile(output io.Writer, steps []replayStep) error {
+	if err := writeReplayHeader(output); err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if err := writeReplayStep(output, step); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeReplayStep(output io.Writer, step replayStep) error {
+	buf := new(bytes.Buffer)
+
+	if step.thinkTime > 0 {
+		fmt.Fprintf(buf, "# think-time=%s\n", step.thinkTime.Round(100*time.Millisecond))
+	}
+	if step.title != "" {
+		fmt.Fprintf(buf, "# title=%s\n", step.title)
+	}
+	if step.questionType != "" {
+		fmt.Fprintf(buf, "# question-type=%s\n", step.questionType)
+	}
+	if len(step.questions) > 0 {
+		fmt.Fprintf(buf, "# questions=%s\n", strings.Join(step.questions, " "))
+	}
+	if len(step.randomize) > 0 {
+		fmt.Fprintf(buf, "# random=%s\n", strings.Join(step.randomize, " "))
+	}
+	if len(step.fromSample) > 0 {
+		fmt.Fprintf(buf, "# sample=%s\n", formatSampleColumns(step.fromSample))
+	}
+
+	keys := []string{}
+	for key := range step.answers {
+		if key != "screenId" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// a key with several values (e.g. a multi-coded answer) is written on
+	// a line per value
+	for _, key := range keys {
+		for _, value := range step.answers[key] {
+			fmt.Fprintf(buf, "%s=[%s]\n", key, value)
+		}
+	}
+
+	buf.WriteString(replayStepSeparator)
+
+	_, err := output.Write(buf.Bytes())
+	return err
+}
+
+func parseReplayFile(file *os.File) []replayStep {
+	buf := bytes.NewBuffer(nil)
+	io.Copy(buf, file) // Error handling elided for brevity.
+
+	return parseReplaySteps(buf.String())
+}
+
+func parseReplaySteps(content string) []replayStep {
+	questions := strings.Split(content, replayStepSeparator)
+	steps := []replayStep{}
+
+	for _, question := range questions {
+		if strings.TrimSpace(question) != "" {
+			steps = append(steps, parseReplayQuestion(question))
+		}
+	}
+
+	if getReplayFormat(content) == replayFormatOld {
+		steps = upgradeReplaySteps(steps)
+	}
+
+	return steps
+}
+
+func getReplayFormat(content string) int {
+	if !strings.HasPrefix(content, replayFormatHeader) {
+		return replayFormatOld
+	}
+
+	line := strings.SplitN(strings.TrimPrefix(content, replayFormatHeader), "\n", 2)[0]
+	format, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return replayFormatOld
+	}
+
+	return format
+}
+
+var multiCodedAnswerKey = regexp.MustCompile(`^answer-q\d+-m$`)
+
+// upgradeReplaySteps splits the codes of multi-coded answers in files of
+// the old format, which were written on one line.
+func upgradeReplaySteps(steps []replayStep) []replayStep {
+	for _, step := range steps {
+		for key, values := range step.answers {
+			if !multiCodedAnswerKey.MatchString(key) || len(values) != 1 {
+				continue
+			}
+
+			codes := strings.Fields(values[0])
+			if len(codes) < 2 {
+				continue
+			}
+
+			allNumbers := true
+			for _, code := range codes {
+				if _, err := strconv.Atoi(code); err != nil {
+					allNumbers = false
+				}
+			}
+
+			if allNumbers {
+				step.answers[key] = codes
+			}
+		}
+	}
+
+	return steps
+}
+
+func parseReplayQuestion(question string) replayStep {
+	lines := strings.FieldsFunc(question, func(char rune) bool { return char == '\n' })
+	printVerbose("replay", "question\n")
+	result := replayStep{answers: url.Values{}}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			parseReplayComment(strings.TrimSpace(strings.TrimPrefix(line, "#")), &result)
+			continue
+		}
+
+		splitLine := strings.SplitN(line, "=", 2)
+		if len(splitLine) < 2 {
+			continue
+		}
+
+		key := splitLine[0]
+		valuesString := splitLine[1]
+
+		values := strings.Trim(valuesString, "[]")
+
+		printVerbose("replay", "key: %s, value: %s\n", key, values)
+
+		result.answers.Add(key, values)
+	}
+
+	return result
+}
+
+func parseReplayComment(comment string, step *replayStep) {
+	splitComment := strings.SplitN(comment, "=", 2)
+	if len(splitComment) < 2 {
+		return
+	}
+
+	value := strings.TrimSpace(splitComment[1])
+
+	switch strings.TrimSpace(splitComment[0]) {
+	case "think-time":
+		if duration, err := time.ParseDuration(value); err == nil {
+			step.thinkTime = duration
+		}
+	case "title":
+		step.title = value
+	case "question-type":
+		step.questionType = value
+	case "questions":
+		step.questions = strings.Fields(value)
+	case "random":
+		step.randomize = strings.Fields(value)
+	case "sample":
+		step.fromSample = map[string]string{}
+		for _, field := range strings.Fields(value) {
+			if splitField := strings.SplitN(field, ":", 2); len(splitField) == 2 {
+				step.fromSample[splitField[0]] = splitField[1]
+			}
+		}
+	}
+}
+
+// formatSampleColumns formats question to column pairs as q1:age q2:city.
+func formatSampleColumns(columns map[string]string) string {
+	fields := []string{}
+	for question, column := range columns {
+		fields = append(fields, question+":"+column)
+	}
+	sort.Strings(fields)
+
+	return strings.Join(fields, " ")
+}
+
+// findReplayStep returns the index of the first step from start on that
+// answers the given questions, or -1.
+func findReplayStep(steps []replayStep, start int, questions []string) int {
+	for i := start; i < len(steps); i++ {
+		if sameQuestions(steps[i].questions, questions) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func sameQuestions(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
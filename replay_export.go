@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// newReplayExport creates a listener that writes the answers posted in
+// every interview to a replay file in dir, so the interview can be
+// replayed exactly with the replay command. Abandoned interviews are left
+// out.
+func newReplayExport(dir string) (eventListener, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	running := make(map[int][]replayStep)
+	detected := make(map[int]replayStep)
+	var lock sync.Mutex
+
+	return func(event interviewEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch event.Event {
+		case eventInterviewStarted:
+			running[event.Interview] = []replayStep{}
+		case eventQuestionDetected:
+			detected[event.Interview] = replayStep{questionType: event.QuestionType, questions: event.Questions}
+		case eventAnswersPosted:
+			// navigation (back, clear) is posted without detecting questions
+			step := detected[event.Interview]
+			step.answers = event.Answers
+			delete(detected, event.Interview)
+
+			running[event.Interview] = append(running[event.Interview], step)
+		case eventInterviewCompleted, eventInterviewErrored:
+			if err := writeReplayExport(dir, event, running[event.Interview]); err != nil {
+				printOutputError(fmt.Errorf("could not write replay file for interview %d: %v", event.Interview, err))
+			}
+			delete(running, event.Interview)
+			delete(detected, event.Interview)
+		case eventInterviewAbandoned:
+			// a replay of a broken-off interview would never reach the end
+			delete(running, event.Interview)
+			delete(detected, event.Interview)
+		}
+	}, nil
+}
+
+func getReplayExportFileName(dir string, event interviewEvent) string {
+	name := fmt.Sprintf("interview-%04d", event.Interview)
+	if event.RespondentKey != "" {
+		name += "-" + unsafePathCharacters.ReplaceAllString(event.RespondentKey, "_")
+	}
+
+	return filepath.Join(dir, name+".replay")
+}
+
+func writeReplayExport(dir string, event interviewEvent, steps []replayStep) error {
+	file, err := os.Create(getReplayExportFileName(dir, event))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writeReplayFile(file, steps)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayExportCanBeReplayed(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "replays")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	numberOfRequests := 0
+	setupMocking(t, "pages/test-interview", &numberOfRequests)
+
+	listener, err := newReplayExport(dir)
+	assert.NoError(err)
+	eventListeners = []eventListener{listener}
+	defer func() { eventListeners = nil }()
+
+	tracker := newInterviewTracker(3, "abc/1")
+	tracker.start()
+	err = performInterview(http.Client{}, &completeConfig.interviewURL, tracker)
+	assert.NoError(err)
+	tracker.finish(err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "interview-0003-abc_1.replay"))
+	assert.NoError(err)
+
+	steps := parseReplaySteps(string(content))
+	assert.Len(steps, 12)
+	assert.Equal([]string{"q10"}, steps[1].questions)
+	assert.NotEmpty(steps[1].answers.Get("answer-q10"))
+	assert.Empty(steps[1].answers.Get("screenId"))
+
+	eventListeners = nil
+	numberOfRequests = 0
+	currentStatus.replaySteps = &steps
+
+	err = performReplay(http.Client{}, &completeConfig.interviewURL, newInterviewTracker(0, ""))
+	assert.NoError(err)
+	assert.Equal(13, numberOfRequests)
+}
+
+func TestReplayExportReportsWriteErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "replays")
+	assert.NoError(err)
+
+	globalConfig = &globalConfiguration{}
+	currentStatus = &completeStatus{}
+	collectedErrors = &errorCollector{}
+
+	listener, err := newReplayExport(dir)
+	assert.NoError(err)
+	os.RemoveAll(dir)
+
+	listener(interviewEvent{Event: eventInterviewStarted, Interview: 1})
+	listener(interviewEvent{Event: eventInterviewCompleted, Interview: 1})
+
+	groups := collectedErrors.summary()
+	assert.Len(groups, 1)
+	assert.Contains(groups[0].example.Error(), "could not write replay file for interview 1")
+	assert.True(hasRunFailed(), "the run fails when a replay file can't be written")
+}
+
+func TestReplayExportSkipsAbandonedInterviews(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "replays")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	listener, err := newReplayExport(dir)
+	assert.NoError(err)
+
+	listener(interviewEvent{Event: eventInterviewStarted, Interview: 1})
+	listener(interviewEvent{Event: eventInterviewAbandoned, Interview: 1})
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	assert.Empty(files)
+}
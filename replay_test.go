@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAndParseReplayStep(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := new(bytes.Buffer)
+	err := writeReplayStep(buf, replayStep{
+		answers:      url.Values{"answer-q1": {"q1-2"}, "screenId": {"abc"}, "button-next": {"Next"}},
+		thinkTime:    4230 * time.Millisecond,
+		title:        "Question 1",
+		questionType: qTypeCategory,
+		questions:    []string{"q1", "q2"},
+	})
+	assert.NoError(err)
+
+	assert.Equal("# think-time=4.2s\n"+
+		"# title=Question 1\n"+
+		"# question-type=Category\n"+
+		"# questions=q1 q2\n"+
+		"answer-q1=[q1-2]\n"+
+		"button-next=[Next]\n"+
+		"---\n", buf.String())
+
+	steps := parseReplaySteps(buf.String())
+	assert.Len(steps, 1)
+	assert.Equal(4200*time.Millisecond, steps[0].thinkTime)
+	assert.Equal("Question 1", steps[0].title)
+	assert.Equal(qTypeCategory, steps[0].questionType)
+	assert.Equal([]string{"q1", "q2"}, steps[0].questions)
+	assert.Equal(url.Values{"answer-q1": {"q1-2"}, "button-next": {"Next"}}, steps[0].answers)
+}
+
+func TestParseReplayStepsWithoutContext(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	steps := parseReplaySteps("answer-q1=[q1-1]\nhistoryOrder=[0]\n---\nanswer-q2=[a=b]\n---\n")
+	assert.Len(steps, 2)
+	assert.Equal("q1-1", steps[0].answers.Get("answer-q1"))
+	assert.Equal("a=b", steps[1].answers.Get("answer-q2"))
+	assert.Empty(steps[1].questions)
+	assert.Zero(steps[1].thinkTime)
+}
+
+func TestFindReplayStep(t *testing.T) {
+	assert := assert.New(t)
+
+	steps := []replayStep{
+		{questions: []string{"q1"}},
+		{questions: []string{"q2"}},
+		{questions: []string{"q3", "q4"}},
+	}
+
+	assert.Equal(0, findReplayStep(steps, 0, []string{"q1"}))
+	assert.Equal(2, findReplayStep(steps, 1, []string{"q3", "q4"}))
+	assert.Equal(-1, findReplayStep(steps, 1, []string{"q1"}))
+	assert.Equal(-1, findReplayStep(steps, 0, []string{"q3"}))
+}
+
+// testInterviewSteps returns a step for every page of pages/test-interview.
+func testInterviewSteps() []replayStep {
+	steps := []replayStep{}
+	for _, questions := range [][]string{
+		nil, {"q10"}, {"q20"}, {"q30"}, {"q40"}, {"q50"},
+		nil, {"q60"}, {"q70"}, {"q80"}, {"q90"}, {"q100"},
+	} {
+		steps = append(steps, replayStep{answers: url.Values{"button-next": {"Next"}}, questions: questions})
+	}
+
+	return steps
+}
+
+func TestPerformReplaySkipsStepsForOtherQuestions(t *testing.T) {
+	assert := assert.New(t)
+
+	numberOfRequests := 0
+	setupMocking(t, "pages/test-interview", &numberOfRequests)
+
+	steps := testInterviewSteps()
+	routed := append([]replayStep{}, steps[:2]...)
+	routed = append(routed, replayStep{answers: url.Values{"answer-q15": {"1"}}, questions: []string{"q15"}})
+	routed = append(routed, steps[2:]...)
+	currentStatus.replaySteps = &routed
+
+	err := performReplay(http.Client{}, &completeConfig.interviewURL, newInterviewTracker(0, ""))
+	assert.NoError(err)
+	assert.Equal(13, numberOfRequests)
+}
+
+func TestPerformReplayFailsOnUnknownQuestions(t *testing.T) {
+	assert := assert.New(t)
+
+	numberOfRequests := 0
+	setupMocking(t, "pages/test-interview", &numberOfRequests)
+
+	steps := testInterviewSteps()
+	steps[3].questions = []string{"q31"}
+	currentStatus.replaySteps = &steps
+
+	err := performReplay(http.Client{}, &completeConfig.interviewURL, newInterviewTracker(0, ""))
+
+	var mismatch *replayMismatchError
+	assert.ErrorAs(err, &mismatch)
+	assert.Contains(err.Error(), "q30")
+}
+
+func TestReplayStepWithSeveralValues(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	buf := new(bytes.Buffer)
+	answers := url.Values{"answer-q1-m": {"3", "5"}, "answer-q2": {"some open text"}}
+	assert.NoError(writeReplayStep(buf, replayStep{answers: answers}))
+
+	assert.Equal("answer-q1-m=[3]\nanswer-q1-m=[5]\nanswer-q2=[some open text]\n---\n", buf.String())
+	assert.Equal(answers, parseReplaySteps(buf.String())[0].answers)
+}
+
+func TestUpgradeOldReplayFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	old := "answer-q1-m=[3 5]\nanswer-q2=[some open text]\n---\n"
+	assert.Equal(replayFormatOld, getReplayFormat(old))
+
+	steps := parseReplaySteps(old)
+	assert.Equal([]string{"3", "5"}, steps[0].answers["answer-q1-m"])
+	assert.Equal([]string{"some open text"}, steps[0].answers["answer-q2"])
+
+	buf := new(bytes.Buffer)
+	assert.NoError(writeReplayFile(buf, steps))
+	assert.Equal(replayFormatCurrent, getReplayFormat(buf.String()))
+	assert.Equal(steps, parseReplaySteps(buf.String()))
+}
+
+func TestPerformReplayReportsQuestions(t *testing.T) {
+	assert := assert.New(t)
+
+	numberOfRequests := 0
+	setupMocking(t, "pages/test-interview", &numberOfRequests)
+
+	steps := testInterviewSteps()
+	steps[1].answers = url.Values{"answer-q10-m": {"3"}, "answer-q10": {"q10-3"}}
+	currentStatus.replaySteps = &steps
+
+	export := newResponsesExport("")
+	eventListeners = []eventListener{export.handleEvent}
+	defer func() { eventListeners = nil }()
+
+	tracker := newInterviewTracker(0, "")
+	tracker.start()
+	err := performReplay(http.Client{}, &completeConfig.interviewURL, tracker)
+	assert.NoError(err)
+	tracker.finish(err)
+
+	assert.Len(export.rows, 1)
+	assert.Equal("3", export.rows[0].answers["q10"])
+}
+
+func TestPerformReplayWithoutSampleKeepsStartURL(t *testing.T) {
+	assert := assert.New(t)
+
+	numberOfRequests := 0
+	setupMocking(t, "pages/test-interview", &numberOfRequests)
+
+	steps := testInterviewSteps()
+	currentStatus.replaySteps = &steps
+
+	mockedGetContent := getContent
+	requested := []string{}
+	getContent = func(client http.Client, url *string) (pageContent, error) {
+		requested = append(requested, *url)
+		return mockedGetContent(client, url)
+	}
+
+	err := performReplay(http.Client{}, &completeConfig.interviewURL, newInterviewTracker(4, ""))
+	assert.NoError(err)
+	assert.Equal([]string{"pages/test-interview"}, requested)
+}
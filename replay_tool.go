@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// executeReplayToolCommand runs the replay subcommands that inspect and
+// edit replay files.
+func executeReplayToolCommand(command string) {
+	var err error
+
+	switch command {
+	case replayListCommand.FullCommand():
+		err = listReplayFile(os.Stdout, *replayListFileArg)
+	case replayDiffCommand.FullCommand():
+		var different bool
+		different, err = diffReplayFiles(os.Stdout, *replayDiffFileArg, *replayDiffOtherArg)
+		if err == nil && different {
+			os.Exit(1)
+		}
+	case replayMergeCommand.FullCommand():
+		err = mergeReplayFiles(*replayMergeOutputArg, *replayMergeInputsArg)
+	case replayDropCommand.FullCommand():
+		err = editReplayFile(*replayDropFileArg, func(steps []replayStep) ([]replayStep, error) {
+			return dropReplayStep(steps, *replayDropStepArg)
+		})
+	case replayReplaceCommand.FullCommand():
+		err = editReplayFile(*replayReplaceFileArg, func(steps []replayStep) ([]replayStep, error) {
+			answers, err := parseAnswerArguments(*replayReplaceValueArg)
+			if err != nil {
+				return nil, err
+			}
+			return replaceReplayStep(steps, *replayReplaceStepArg, answers)
+		})
+	case replayUpgradeCommand.FullCommand():
+		err = upgradeReplayFiles(os.Stdout, *replayUpgradeFilesArg)
+	default:
+		err = fmt.Errorf("Unknown command")
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func readReplayFile(path string) ([]replayStep, int, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return parseReplaySteps(string(content)), getReplayFormat(string(content)), nil
+}
+
+func saveReplayFile(path string, steps []replayStep) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := writeReplayFile(file, steps); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func listReplayFile(output io.Writer, path string) error {
+	steps, _, err := readReplayFile(path)
+	if err != nil {
+		return err
+	}
+
+	summarizeReplay(output, steps)
+	return nil
+}
+
+// summarizeReplay prints every step with its questions and answers.
+func summarizeReplay(output io.Writer, steps []replayStep) {
+	for i, step := range steps {
+		description := []string{fmt.Sprintf("Step %d:", i+1)}
+
+		if len(step.questions) > 0 {
+			description = append(description, strings.Join(step.questions, ", "))
+		} else {
+			description = append(description, "(no questions)")
+		}
+		if step.questionType != "" {
+			description = append(description, "("+step.questionType+")")
+		}
+		if step.title != "" {
+			description = append(description, fmt.Sprintf("%q", step.title))
+		}
+		if step.thinkTime > 0 {
+			description = append(description, "after "+step.thinkTime.String())
+		}
+
+		fmt.Fprintln(output, strings.Join(description, " "))
+
+		if len(step.randomize) > 0 || len(step.fromSample) > 0 {
+			fmt.Fprintf(output, "    tags: %s\n", describeAnnotations(step))
+		}
+
+		for _, answer := range formatAnswers(step.answers, false) {
+			fmt.Fprintf(output, "    %s\n", answer)
+		}
+	}
+
+	fmt.Fprintf(output, "%d step(s)\n", len(steps))
+}
+
+// formatAnswers returns the answers as sorted key=value lines; with
+// onlyAnswers, navigation fields like historyOrder are left out.
+func formatAnswers(answers url.Values, onlyAnswers bool) []string {
+	result := []string{}
+	for key, values := range answers {
+		if key == "screenId" || (onlyAnswers && !strings.HasPrefix(key, "answer-")) {
+			continue
+		}
+
+		result = append(result, fmt.Sprintf("%s=%s", key, strings.Join(values, ",")))
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func diffReplayFiles(output io.Writer, path string, otherPath string) (bool, error) {
+	steps, _, err := readReplayFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	otherSteps, _, err := readReplayFile(otherPath)
+	if err != nil {
+		return false, err
+	}
+
+	return diffReplays(output, steps, otherSteps), nil
+}
+
+// diffReplays prints the differences in answers per question, and tells
+// whether there are any. Steps without page context are compared by their
+// number.
+func diffReplays(output io.Writer, steps []replayStep, otherSteps []replayStep) bool {
+	keys, answers := groupReplayAnswers(steps)
+	otherKeys, otherAnswers := groupReplayAnswers(otherSteps)
+
+	for _, key := range otherKeys {
+		if _, ok := answers[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	different := false
+	for _, key := range keys {
+		count := len(answers[key])
+		if len(otherAnswers[key]) > count {
+			count = len(otherAnswers[key])
+		}
+
+		for i := 0; i < count; i++ {
+			name := key
+			if count > 1 {
+				name = fmt.Sprintf("%s (#%d)", key, i+1)
+			}
+
+			switch {
+			case i >= len(otherAnswers[key]):
+				fmt.Fprintf(output, "%s: only in first file\n", name)
+			case i >= len(answers[key]):
+				fmt.Fprintf(output, "%s: only in second file\n", name)
+			case answers[key][i] != otherAnswers[key][i]:
+				fmt.Fprintf(output, "%s:\n  - %s\n  + %s\n", name, answers[key][i], otherAnswers[key][i])
+			default:
+				continue
+			}
+
+			different = true
+		}
+	}
+
+	if !different {
+		fmt.Fprintln(output, "No differences.")
+	}
+
+	return different
+}
+
+// groupReplayAnswers returns the answers of the steps by their questions,
+// in the order they first appear.
+func groupReplayAnswers(steps []replayStep) ([]string, map[string][]string) {
+	keys := []string{}
+	answers := map[string][]string{}
+
+	for i, step := range steps {
+		key := strings.Join(step.questions, ", ")
+		if key == "" {
+			key = fmt.Sprintf("step %d", i+1)
+		}
+
+		if _, ok := answers[key]; !ok {
+			keys = append(keys, key)
+		}
+		answers[key] = append(answers[key], strings.Join(formatAnswers(step.answers, true), " "))
+	}
+
+	return keys, answers
+}
+
+func mergeReplayFiles(outputPath string, paths []string) error {
+	merged := []replayStep{}
+	for _, path := range paths {
+		steps, _, err := readReplayFile(path)
+		if err != nil {
+			return err
+		}
+		merged = append(merged, steps...)
+	}
+
+	if err := saveReplayFile(outputPath, merged); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d step(s) to \"%s\".\n", len(merged), outputPath)
+	return nil
+}
+
+// editReplayFile changes the steps of a replay file in place.
+func editReplayFile(path string, edit func([]replayStep) ([]replayStep, error)) error {
+	steps, _, err := readReplayFile(path)
+	if err != nil {
+		return err
+	}
+
+	steps, err = edit(steps)
+	if err != nil {
+		return err
+	}
+
+	return saveReplayFile(path, steps)
+}
+
+func dropReplayStep(steps []replayStep, number int) ([]replayStep, error) {
+	if number < 1 || number > len(steps) {
+		return nil, fmt.Errorf("step %d does not exist; the replay file has %d step(s)", number, len(steps))
+	}
+
+	result := append([]replayStep{}, steps[:number-1]...)
+	return append(result, steps[number:]...), nil
+}
+
+// replaceReplayStep replaces the answers of a step; its page context is
+// kept.
+func replaceReplayStep(steps []replayStep, number int, answers url.Values) ([]replayStep, error) {
+	if number < 1 || number > len(steps) {
+		return nil, fmt.Errorf("step %d does not exist; the replay file has %d step(s)", number, len(steps))
+	}
+
+	result := append([]replayStep{}, steps...)
+	result[number-1].answers = answers
+	return result, nil
+}
+
+func parseAnswerArguments(arguments []string) (url.Values, error) {
+	answers := url.Values{}
+	for _, argument := range arguments {
+		splitArgument := strings.SplitN(argument, "=", 2)
+		if len(splitArgument) < 2 || splitArgument[0] == "" {
+			return nil, fmt.Errorf("answer '%s' should look like key=value", argument)
+		}
+
+		answers.Add(splitArgument[0], splitArgument[1])
+	}
+
+	return answers, nil
+}
+
+func upgradeReplayFiles(output io.Writer, paths []string) error {
+	for _, path := range paths {
+		steps, format, err := readReplayFile(path)
+		if err != nil {
+			return err
+		}
+
+		if format >= replayFormatCurrent {
+			fmt.Fprintf(output, "\"%s\" is already in the current format.\n", path)
+			continue
+		}
+
+		// steps are upgraded while reading
+		if err := saveReplayFile(path, steps); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(output, "Upgraded \"%s\".\n", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := new(bytes.Buffer)
+	summarizeReplay(buf, []replayStep{
+		{
+			answers:      url.Values{"answer-q1": {"q1-2"}, "screenId": {"abc"}},
+			thinkTime:    2 * time.Second,
+			title:        "Question 1",
+			questionType: qTypeCategory,
+			questions:    []string{"q1"},
+			randomize:    []string{"q1"},
+		},
+		{answers: url.Values{"button-next": {"Next"}}},
+	})
+
+	assert.Equal("Step 1: q1 (Category) \"Question 1\" after 2s\n"+
+		"    tags: q1: random\n"+
+		"    answer-q1=q1-2\n"+
+		"Step 2: (no questions)\n"+
+		"    button-next=Next\n"+
+		"2 step(s)\n", buf.String())
+}
+
+func TestDiffReplays(t *testing.T) {
+	assert := assert.New(t)
+
+	steps := []replayStep{
+		{questions: []string{"q1"}, answers: url.Values{"answer-q1": {"q1-1"}, "historyOrder": {"0"}}},
+		{questions: []string{"q2"}, answers: url.Values{"answer-q2": {"text"}}},
+		{questions: []string{"q3"}, answers: url.Values{"answer-q3": {"5"}}},
+	}
+	otherSteps := []replayStep{
+		{questions: []string{"q1"}, answers: url.Values{"answer-q1": {"q1-1"}, "historyOrder": {"1"}}},
+		{questions: []string{"q2"}, answers: url.Values{"answer-q2": {"other text"}}},
+		{questions: []string{"q4"}, answers: url.Values{"answer-q4": {"q4-1"}}},
+	}
+
+	buf := new(bytes.Buffer)
+	assert.True(diffReplays(buf, steps, otherSteps))
+	assert.Equal("q2:\n"+
+		"  - answer-q2=text\n"+
+		"  + answer-q2=other text\n"+
+		"q3: only in first file\n"+
+		"q4: only in second file\n", buf.String())
+
+	buf.Reset()
+	assert.False(diffReplays(buf, steps, steps))
+	assert.Equal("No differences.\n", buf.String())
+}
+
+func TestDropAndReplaceReplayStep(t *testing.T) {
+	assert := assert.New(t)
+
+	steps := []replayStep{
+		{questions: []string{"q1"}, answers: url.Values{"answer-q1": {"q1-1"}}},
+		{questions: []string{"q2"}, answers: url.Values{"answer-q2": {"text"}}},
+	}
+
+	dropped, err := dropReplayStep(steps, 1)
+	assert.NoError(err)
+	assert.Len(dropped, 1)
+	assert.Equal([]string{"q2"}, dropped[0].questions)
+	assert.Len(steps, 2)
+
+	_, err = dropReplayStep(steps, 3)
+	assert.Error(err)
+
+	answers, err := parseAnswerArguments([]string{"answer-q2=a=b", "answer-q2=c"})
+	assert.NoError(err)
+
+	replaced, err := replaceReplayStep(steps, 2, answers)
+	assert.NoError(err)
+	assert.Equal([]string{"q2"}, replaced[1].questions)
+	assert.Equal([]string{"a=b", "c"}, replaced[1].answers["answer-q2"])
+	assert.Equal("text", steps[1].answers.Get("answer-q2"))
+
+	_, err = parseAnswerArguments([]string{"answer-q2"})
+	assert.Error(err)
+}
+
+func TestMergeAndUpgradeReplayFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	globalConfig = &globalConfiguration{}
+
+	dir, err := ioutil.TempDir("", "replays")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "first.replay")
+	second := filepath.Join(dir, "second.replay")
+	merged := filepath.Join(dir, "merged.replay")
+
+	assert.NoError(ioutil.WriteFile(first, []byte("answer-q1-m=[1 2]\n---\n"), 0644))
+	assert.NoError(saveReplayFile(second, []replayStep{{answers: url.Values{"answer-q2": {"text"}}}}))
+
+	buf := new(bytes.Buffer)
+	assert.NoError(upgradeReplayFiles(buf, []string{first, second}))
+	assert.Contains(buf.String(), "Upgraded")
+	assert.Contains(buf.String(), "already in the current format")
+
+	content, err := ioutil.ReadFile(first)
+	assert.NoError(err)
+	assert.Equal("# replay-format=2\nanswer-q1-m=[1]\nanswer-q1-m=[2]\n---\n", string(content))
+
+	assert.NoError(mergeReplayFiles(merged, []string{first, second}))
+
+	steps, format, err := readReplayFile(merged)
+	assert.NoError(err)
+	assert.Equal(replayFormatCurrent, format)
+	assert.Len(steps, 2)
+	assert.Equal([]string{"1", "2"}, steps[0].answers["answer-q1-m"])
+	assert.Equal("text", steps[1].answers.Get("answer-q2"))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// keyPart produces one segment of a respondent key. Random segments must
+// draw from the given generator, so the same run id and interview number
+// always produce the same key.
+type keyPart func(number int, generator *rand.Rand) string
+
+type keyTemplate struct {
+	parts   []keyPart
+	runID   string
+	started time.Time
+}
+
+// parseKeyTemplate parses a respondent key template. A run of percentage
+// signs is a zero-padded interview number; placeholders are written as
+// {name[+offset][:argument]}:
+//
+//	{n}, {n:6}, {n+1000:6}   interview number, with optional offset and width
+//	{rand}, {rand:12}        random lowercase alphanumeric segment (default 8)
+//	{uuid}                   random uuid (version 4)
+//	{run}                    the run id
+//	{date}, {date:20060102}  start of the run, formatted with a Go time layout
+//	{hash}, {hash:16}        hex hash of run id and interview number (default 8)
+//
+// Use {{ and }} for literal braces.
+func parseKeyTemplate(template string, runID string) (*keyTemplate, error) {
+	result := &keyTemplate{runID: runID, started: time.Now()}
+	isUnique := false
+	literal := ""
+	runes := []rune(template)
+
+	flushLiteral := func() {
+		if literal != "" {
+			text := literal
+			result.parts = append(result.parts, func(int, *rand.Rand) string { return text })
+			literal = ""
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '%':
+			width := 0
+			for i < len(runes) && runes[i] == '%' {
+				width++
+				i++
+			}
+			i--
+
+			flushLiteral()
+			result.parts = append(result.parts, numberKeyPart(0, width))
+			isUnique = true
+		case runes[i] == '{' && i+1 < len(runes) && runes[i+1] == '{',
+			runes[i] == '}' && i+1 < len(runes) && runes[i+1] == '}':
+			literal += string(runes[i])
+			i++
+		case runes[i] == '{':
+			end := i + 1
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("invalid respondent key '%s': unterminated placeholder", template)
+			}
+
+			part, unique, err := parseKeyPlaceholder(string(runes[i+1:end]), result)
+			if err != nil {
+				return nil, fmt.Errorf("invalid respondent key '%s': %v", template, err)
+			}
+
+			flushLiteral()
+			result.parts = append(result.parts, part)
+			isUnique = isUnique || unique
+			i = end
+		case runes[i] == '}':
+			return nil, fmt.Errorf("invalid respondent key '%s': unexpected '}'", template)
+		default:
+			literal += string(runes[i])
+		}
+	}
+
+	flushLiteral()
+
+	if !isUnique {
+		return nil, fmt.Errorf("invalid respondent key '%s': must contain a percentage sign, {n}, {rand}, {uuid} or {hash}", template)
+	}
+
+	return result, nil
+}
+
+func parseKeyPlaceholder(placeholder string, template *keyTemplate) (keyPart, bool, error) {
+	name := placeholder
+	argument := ""
+	offset := 0
+
+	if index := strings.IndexRune(name, ':'); index >= 0 {
+		argument = name[index+1:]
+		name = name[:index]
+	}
+	if index := strings.IndexRune(name, '+'); index >= 0 {
+		value, err := strconv.Atoi(name[index+1:])
+		if err != nil || value < 0 {
+			return nil, false, fmt.Errorf("invalid offset in {%s}", placeholder)
+		}
+		offset = value
+		name = name[:index]
+	}
+
+	if offset != 0 && name != "n" {
+		return nil, false, fmt.Errorf("only {n} supports an offset")
+	}
+
+	intArgument := func(defaultValue int, maxValue int) (int, error) {
+		if argument == "" {
+			return defaultValue, nil
+		}
+		value, err := strconv.Atoi(argument)
+		if err != nil || value < 1 || value > maxValue {
+			return 0, fmt.Errorf("invalid length in {%s}", placeholder)
+		}
+		return value, nil
+	}
+
+	switch name {
+	case "n":
+		width, err := intArgument(0, 32)
+		return numberKeyPart(offset, width), true, err
+	case "rand":
+		length, err := intArgument(8, 64)
+		return func(_ int, generator *rand.Rand) string {
+			result := make([]byte, length)
+			for i := range result {
+				result[i] = alphanumeric[generator.Intn(len(alphanumeric))]
+			}
+			return string(result)
+		}, true, err
+	case "uuid":
+		return func(_ int, generator *rand.Rand) string {
+			bytes := make([]byte, 16)
+			generator.Read(bytes)
+			bytes[6] = (bytes[6] & 0x0f) | 0x40
+			bytes[8] = (bytes[8] & 0x3f) | 0x80
+			return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:])
+		}, true, nil
+	case "run":
+		return func(int, *rand.Rand) string { return template.runID }, false, nil
+	case "date":
+		layout := argument
+		if layout == "" {
+			layout = "20060102"
+		}
+		return func(int, *rand.Rand) string { return template.started.Format(layout) }, false, nil
+	case "hash":
+		length, err := intArgument(8, sha256.Size*2)
+		return func(number int, _ *rand.Rand) string {
+			sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", template.runID, number)))
+			return hex.EncodeToString(sum[:])[:length]
+		}, true, err
+	}
+
+	return nil, false, fmt.Errorf("unknown placeholder {%s}", placeholder)
+}
+
+func numberKeyPart(offset int, width int) keyPart {
+	return func(number int, _ *rand.Rand) string {
+		return fmt.Sprintf("%0*d", width, number+offset)
+	}
+}
+
+func (template *keyTemplate) format(number int) string {
+	seed := fnv.New64a()
+	fmt.Fprintf(seed, "%s/%d", template.runID, number)
+	generator := rand.New(rand.NewSource(int64(seed.Sum64())))
+
+	result := ""
+	for _, part := range template.parts {
+		result += part(number, generator)
+	}
+
+	return result
+}
+
+func newRunID() string {
+	result := make([]byte, 6)
+	for i := range result {
+		result[i] = alphanumeric[random.Intn(len(alphanumeric))]
+	}
+	return string(result)
+}
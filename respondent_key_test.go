@@ -0,0 +1,75 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentageKeyTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	template, err := parseKeyTemplate("resp%%%%x", "run")
+	assert.NoError(err)
+
+	assert.Equal("resp0000x", template.format(0))
+	assert.Equal("resp0042x", template.format(42))
+	assert.Equal("resp12345x", template.format(12345))
+}
+
+func TestNumberKeyTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	template, err := parseKeyTemplate("team-a-{n+1000:6}", "run")
+	assert.NoError(err)
+
+	assert.Equal("team-a-001000", template.format(0))
+	assert.Equal("team-a-001007", template.format(7))
+}
+
+func TestRandomKeyTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	template, err := parseKeyTemplate("{run}-{rand:5}-{uuid}-{hash:10}", "abc123")
+	assert.NoError(err)
+
+	key := template.format(3)
+	assert.Regexp(regexp.MustCompile(
+		`^abc123-[a-z0-9]{5}-[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}-[0-9a-f]{10}$`), key)
+
+	assert.Equal(key, template.format(3), "keys are reproducible for the same run id")
+	assert.NotEqual(key, template.format(4))
+
+	other, err := parseKeyTemplate("{run}-{rand:5}-{uuid}-{hash:10}", "def456")
+	assert.NoError(err)
+	assert.NotEqual(key[7:], other.format(3)[7:], "different runs get different keys")
+}
+
+func TestDateKeyTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	template, err := parseKeyTemplate("{date:2006}-{{%%}}", "run")
+	assert.NoError(err)
+
+	assert.Equal(template.started.Format("2006")+"-{01}", template.format(1))
+}
+
+func TestInvalidKeyTemplates(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, template := range []string{
+		"constant",
+		"{run}-{date}",
+		"{n",
+		"n}",
+		"{unknown}",
+		"{rand:0}",
+		"{rand+1}",
+		"{n+abc}",
+		"{hash:100}",
+	} {
+		_, err := parseKeyTemplate(template, "run")
+		assert.Error(err, template)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type responseRow struct {
+	number        int
+	respondentKey string
+	status        string
+	answers       map[string]string
+}
+
+// responsesExport collects the answers posted in every interview, to write
+// them as a dataset with a row per interview and a column per question.
+type responsesExport struct {
+	lock    sync.Mutex
+	path    string
+	pending map[int]pageInfo
+	running map[int]*responseRow
+	rows    []*responseRow
+}
+
+func newResponsesExport(path string) *responsesExport {
+	return &responsesExport{
+		path:    path,
+		pending: make(map[int]pageInfo),
+		running: make(map[int]*responseRow),
+	}
+}
+
+func (export *responsesExport) handleEvent(event interviewEvent) {
+	export.lock.Lock()
+	defer export.lock.Unlock()
+
+	switch event.Event {
+	case eventInterviewStarted:
+		export.running[event.Interview] = &responseRow{
+			number:        event.Interview,
+			respondentKey: event.RespondentKey,
+			answers:       make(map[string]string),
+		}
+	case eventQuestionDetected:
+		export.pending[event.Interview] = pageInfo{questionType: event.QuestionType, questions: event.Questions}
+	case eventAnswersPosted:
+		page, ok := export.pending[event.Interview]
+		row, running := export.running[event.Interview]
+		if !ok || !running {
+			return
+		}
+		delete(export.pending, event.Interview)
+
+		for _, question := range page.questions {
+			// answering a question again (after going back) replaces the answer
+			if value, ok := getPostedAnswer(question, page.questionType, event.Answers); ok {
+				for column := range row.answers {
+					if strings.HasPrefix(column, question+"-") {
+						delete(row.answers, column)
+					}
+				}
+
+				row.answers[question] = value
+			}
+
+			if page.questionType == qTypeCategory {
+				for column, text := range getCategoryTexts(question, event.Answers) {
+					row.answers[column] = text
+				}
+			}
+		}
+	case eventInterviewCompleted, eventInterviewAbandoned, eventInterviewErrored:
+		row, ok := export.running[event.Interview]
+		if !ok {
+			return
+		}
+		row.status = strings.TrimPrefix(event.Event, "interview-")
+		export.rows = append(export.rows, row)
+		delete(export.running, event.Interview)
+		delete(export.pending, event.Interview)
+	}
+}
+
+func getPostedAnswer(question string, questionType string, answers url.Values) (string, bool) {
+	if questionType == qTypeCategory {
+		codes, ok := answers["answer-"+question+"-m"]
+		return strings.Join(codes, ";"), ok
+	}
+
+	values, ok := answers["answer-"+question]
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+
+	return values[0], true
+}
+
+// getCategoryTexts returns the text posted with categories of a question,
+// e.g. for "other, specify", by column (the key without answer-, like
+// q10-5-other). The values of the category inputs themselves are skipped.
+func getCategoryTexts(question string, answers url.Values) map[string]string {
+	result := map[string]string{}
+	prefix := "answer-" + question + "-"
+
+	for key, values := range answers {
+		suffix := strings.TrimPrefix(key, prefix)
+		if !strings.HasPrefix(key, prefix) || suffix == "m" || len(values) == 0 {
+			continue
+		}
+
+		if values[0] != "" && values[0] != question+"-"+suffix {
+			result[question+"-"+suffix] = values[0]
+		}
+	}
+
+	return result
+}
+
+// columnOrder sorts columns by question number; the text columns of a
+// question (e.g. q10-5-other) follow the question.
+func columnOrder(a string, b string) bool {
+	questionA := strings.SplitN(a, "-", 2)[0]
+	questionB := strings.SplitN(b, "-", 2)[0]
+
+	if questionA != questionB {
+		return questionNumber(questionA) < questionNumber(questionB)
+	}
+
+	return a < b
+}
+
+func (export *responsesExport) write() error {
+	export.lock.Lock()
+	defer export.lock.Unlock()
+
+	questionSet := make(map[string]bool)
+	for _, row := range export.rows {
+		for question := range row.answers {
+			questionSet[question] = true
+		}
+	}
+
+	questions := []string{}
+	for question := range questionSet {
+		questions = append(questions, question)
+	}
+	sort.Slice(questions, func(i, j int) bool { return columnOrder(questions[i], questions[j]) })
+
+	rows := append([]*responseRow{}, export.rows...)
+	sort.Slice(rows, func(i, j int) bool { return rows[i].number < rows[j].number })
+
+	file, err := os.Create(export.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write(append([]string{"respondentkey", "interview", "status"}, questions...))
+
+	for _, row := range rows {
+		record := []string{row.respondentKey, strconv.Itoa(row.number), row.status}
+		for _, question := range questions {
+			record = append(record, row.answers[question])
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponsesCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "responses")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	export := newResponsesExport(filepath.Join(dir, "responses.csv"))
+	eventListeners = []eventListener{export.handleEvent}
+	defer func() { eventListeners = nil }()
+
+	post := func(tracker *interviewTracker, questionType string, question string, answers url.Values) {
+		tracker.questionDetected(pageInfo{questionType: questionType, questions: []string{question}})
+		tracker.emit(interviewEvent{Event: eventAnswersPosted, Answers: answers})
+	}
+
+	second := newInterviewTracker(1, "k1")
+	second.start()
+	post(second, qTypeNumber, "q20", url.Values{"answer-q20": {"12"}})
+	second.finish(errAnswerRejected)
+
+	first := newInterviewTracker(0, "k0")
+	first.start()
+	post(first, qTypeCategory, "q10", url.Values{
+		"answer-q10-m":       {"2", "4"},
+		"answer-q10-2":       {"q10-2"},
+		"answer-q10-4":       {"q10-4"},
+		"answer-q10-4-other": {"something else"},
+	})
+	post(first, qTypeNumber, "q20", url.Values{"answer-q20": {"5"}})
+	// going back and answering again replaces the answer
+	first.emit(interviewEvent{Event: eventAnswersPosted, Answers: url.Values{"button-back": {"Back"}}})
+	post(first, qTypeNumber, "q20", url.Values{"answer-q20": {"7"}})
+	post(first, qTypeOpenSingle, "q3", url.Values{"answer-q3": {"hello, world"}})
+	first.finish(nil)
+
+	assert.NoError(export.write())
+
+	file, err := os.Open(export.path)
+	assert.NoError(err)
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	assert.NoError(err)
+
+	assert.Equal([][]string{
+		{"respondentkey", "interview", "status", "q3", "q10", "q10-4-other", "q20"},
+		{"k0", "0", "completed", "hello, world", "2;4", "something else", "7"},
+		{"k1", "1", "errored", "", "", "", "12"},
+	}, records)
+}
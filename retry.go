@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// withRetries performs the request, retrying connection errors and 5xx/429
+// responses with exponential backoff. Posts are only retried when the
+// server can't have handled them. Any other error is returned as-is.
+func withRetries(request func() (pageContent, error)) (pageContent, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := request()
+
+		if err == nil || attempt >= globalConfig.maxRetries || !isRetryableError(err) {
+			return result, err
+		}
+
+		delay := getRetryDelay(err, attempt)
+		printVerbose("retry", "%v; retrying in %s (attempt %d of %d)\n", err, delay, attempt+1, globalConfig.maxRetries)
+
+		if currentStatus != nil {
+			atomic.AddInt64(&currentStatus.retried, 1)
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// sentRequestError is a network error that happened after the request was
+// written, so the server may already have processed it.
+type sentRequestError struct {
+	err error
+}
+
+func (err *sentRequestError) Error() string {
+	return err.err.Error()
+}
+
+func (err *sentRequestError) Unwrap() error {
+	return err.err
+}
+
+func isRetryableError(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.method == http.MethodPost {
+			// the server may have processed the answers before failing, so
+			// only retry when it says it did not handle the post
+			return statusErr.statusCode == http.StatusTooManyRequests ||
+				(statusErr.statusCode == http.StatusServiceUnavailable && statusErr.retryAfter > 0)
+		}
+
+		return statusErr.statusCode >= 500 || statusErr.statusCode == http.StatusTooManyRequests
+	}
+
+	var sentErr *sentRequestError
+	if errors.As(err, &sentErr) {
+		return false
+	}
+
+	// only retry failures of the connection itself; invalid urls,
+	// unsupported schemes and certificate errors won't go away
+	var networkErr *url.Error
+	if !errors.As(err, &networkErr) || networkErr.Op == "parse" {
+		return false
+	}
+
+	var connectionErr net.Error
+	return errors.As(networkErr.Err, &connectionErr) ||
+		errors.Is(networkErr.Err, io.EOF) ||
+		errors.Is(networkErr.Err, io.ErrUnexpectedEOF)
+}
+
+func getRetryDelay(err error, attempt int) time.Duration {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.retryAfter > 0 {
+		if statusErr.retryAfter > globalConfig.maxRetryWait {
+			return globalConfig.maxRetryWait
+		}
+		return statusErr.retryAfter
+	}
+
+	delay := globalConfig.retryWait << uint(attempt)
+	if delay <= 0 || delay > globalConfig.maxRetryWait {
+		delay = globalConfig.maxRetryWait
+	}
+
+	// add jitter so concurrent interviews don't all retry at the same moment
+	half := int64(delay / 2)
+	if half > 0 {
+		delay = time.Duration(half + random.Int63n(half+1))
+	}
+
+	return delay
+}
+
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// postContent before setupMocking replaces it
+var unmockedPostContent = postContent
+
+func setupRetries(maxRetries int) {
+	globalConfig = &globalConfiguration{
+		maxRetries:   maxRetries,
+		retryWait:    time.Millisecond,
+		maxRetryWait: 5 * time.Millisecond,
+	}
+	currentStatus = &completeStatus{}
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	assert := assert.New(t)
+	setupRetries(3)
+
+	attempts := 0
+	_, err := withRetries(func() (pageContent, error) {
+		attempts++
+		if attempts < 3 {
+			return pageContent{}, &httpStatusError{status: "503 Service Unavailable", statusCode: 503}
+		}
+		return pageContent{}, nil
+	})
+
+	assert.NoError(err)
+	assert.Equal(3, attempts)
+	assert.Equal(int64(2), currentStatus.retried)
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	assert := assert.New(t)
+	setupRetries(2)
+
+	attempts := 0
+	_, err := withRetries(func() (pageContent, error) {
+		attempts++
+		return pageContent{}, &url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+	})
+
+	assert.Error(err)
+	assert.Equal(3, attempts)
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	assert := assert.New(t)
+	setupRetries(3)
+
+	for _, err := range []error{
+		&httpStatusError{status: "404 Not Found", statusCode: 404},
+		fmt.Errorf("validation error in interview (answer rejected)"),
+	} {
+		attempts := 0
+		_, result := withRetries(func() (pageContent, error) {
+			attempts++
+			return pageContent{}, err
+		})
+
+		assert.Equal(err, result)
+		assert.Equal(1, attempts)
+	}
+
+	assert.Equal(int64(0), currentStatus.retried)
+}
+
+func TestRetriesTooManyRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isRetryableError(&httpStatusError{statusCode: 429}))
+	assert.True(isRetryableError(&httpStatusError{statusCode: 500}))
+	assert.False(isRetryableError(&httpStatusError{statusCode: 400}))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(5*time.Second, parseRetryAfter("5"))
+	assert.Equal(time.Duration(0), parseRetryAfter(""))
+	assert.Equal(time.Duration(0), parseRetryAfter("not a date"))
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	wait := parseRetryAfter(date)
+	assert.True(wait > 50*time.Second && wait <= time.Minute, "Retry-After date is respected")
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	assert := assert.New(t)
+	setupRetries(10)
+
+	delay := getRetryDelay(&httpStatusError{statusCode: 503}, 9)
+	assert.True(delay <= globalConfig.maxRetryWait, "delay capped at max retry wait")
+
+	delay = getRetryDelay(&httpStatusError{statusCode: 503, retryAfter: 2 * time.Second}, 0)
+	assert.Equal(globalConfig.maxRetryWait, delay, "Retry-After capped at max retry wait")
+
+	delay = getRetryDelay(&httpStatusError{statusCode: 503, retryAfter: 2 * time.Millisecond}, 0)
+	assert.Equal(2*time.Millisecond, delay)
+}
+
+func TestDoesNotRetryPermanentNetworkErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(isRetryableError(&url.Error{Op: "Get", URL: "ftp://localhost", Err: fmt.Errorf("unsupported protocol scheme \"ftp\"")}))
+	assert.False(isRetryableError(&url.Error{Op: "Get", URL: "https://localhost", Err: x509.UnknownAuthorityError{}}))
+	assert.False(isRetryableError(&url.Error{Op: "parse", URL: "://", Err: fmt.Errorf("missing protocol scheme")}))
+	assert.True(isRetryableError(&url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}))
+}
+
+func TestDoesNotRetryPostsAfterSending(t *testing.T) {
+	assert := assert.New(t)
+	setupRetries(3)
+	completeConfig = &completeConfiguration{}
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		connection, _, _ := response.(http.Hijacker).Hijack()
+		connection.Close()
+	}))
+	defer server.Close()
+
+	serverURL := server.URL
+	_, err := unmockedPostContent(http.Client{}, &serverURL, url.Values{"answer-q1": {"yes"}})
+
+	assert.Error(err)
+	assert.Equal(int32(1), atomic.LoadInt32(&attempts), "answers are not posted twice")
+	assert.Equal(int64(0), currentStatus.retried)
+}
+
+func TestDoesNotRetryPostsThatFailedOnTheServer(t *testing.T) {
+	assert := assert.New(t)
+	setupRetries(3)
+	completeConfig = &completeConfiguration{}
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		http.Error(response, "oops", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	serverURL := server.URL
+	_, err := unmockedPostContent(http.Client{}, &serverURL, url.Values{"answer-q1": {"yes"}})
+
+	var statusErr *httpStatusError
+	assert.ErrorAs(err, &statusErr)
+	assert.Equal(int32(1), atomic.LoadInt32(&attempts), "answers are not posted twice")
+	assert.Equal(int64(0), currentStatus.retried)
+}
+
+func TestRetriesPostsTheServerDidNotHandle(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isRetryableError(&httpStatusError{method: "POST", statusCode: 429}))
+	assert.True(isRetryableError(&httpStatusError{method: "POST", statusCode: 503, retryAfter: time.Second}))
+	assert.False(isRetryableError(&httpStatusError{method: "POST", statusCode: 503}))
+	assert.False(isRetryableError(&httpStatusError{method: "POST", statusCode: 502}))
+	assert.True(isRetryableError(&httpStatusError{method: "GET", statusCode: 502}))
+}
+
+func TestRetriesPostsThatWereNotSent(t *testing.T) {
+	assert := assert.New(t)
+	setupRetries(2)
+	completeConfig = &completeConfiguration{}
+
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	_, err := unmockedPostContent(http.Client{}, &closedURL, url.Values{"answer-q1": {"yes"}})
+
+	assert.Error(err)
+	assert.Equal(int64(2), currentStatus.retried)
+}
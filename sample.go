@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type sampleRow struct {
+	respondentKey string
+	parameters    map[string]string
+	columns       []string
+}
+
+// value returns the value of a column other than the key column; column
+// names are matched case-insensitively.
+func (row sampleRow) value(column string) (string, bool) {
+	if value, ok := row.parameters[column]; ok {
+		return value, true
+	}
+
+	for name, value := range row.parameters {
+		if strings.EqualFold(name, column) {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// readSampleFile reads a csv file with a header row. The column named
+// keyColumn (case-insensitive) holds the respondent key; all other columns
+// are parameters for the interview url.
+func readSampleFile(path string, keyColumn string) ([]sampleRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseSample(file, keyColumn)
+}
+
+func parseSample(input io.Reader, keyColumn string) ([]sampleRow, error) {
+	reader := csv.NewReader(input)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("sample file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keyIndex := -1
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		if strings.EqualFold(header[i], keyColumn) {
+			keyIndex = i
+		}
+	}
+
+	if keyIndex < 0 {
+		return nil, fmt.Errorf("sample file has no column '%s' with the respondent key", keyColumn)
+	}
+
+	rows := []sampleRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := sampleRow{parameters: make(map[string]string)}
+		for i, value := range record {
+			if i == keyIndex {
+				row.respondentKey = value
+			} else {
+				row.parameters[header[i]] = value
+				row.columns = append(row.columns, header[i])
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("sample file has no rows")
+	}
+
+	return rows, nil
+}
+
+// getSampleURL fills in {column} placeholders in the path of interviewURL,
+// appends the respondent key to the path and adds the remaining columns as
+// query parameters.
+func getSampleURL(interviewURL string, row sampleRow) (string, error) {
+	result, err := url.Parse(interviewURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := result.Query()
+	for _, column := range row.columns {
+		placeholder := "{" + column + "}"
+		value := row.parameters[column]
+
+		if strings.Contains(result.Path, placeholder) {
+			result.Path = strings.Replace(result.Path, placeholder, value, -1)
+		} else {
+			query.Set(column, value)
+		}
+	}
+
+	if row.respondentKey != "" {
+		if !strings.HasSuffix(result.Path, "/") {
+			result.Path += "/"
+		}
+		result.Path += row.respondentKey
+	}
+
+	result.RawPath = ""
+	result.RawQuery = query.Encode()
+
+	return result.String(), nil
+}
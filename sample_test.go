@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSample(t *testing.T) {
+	assert := assert.New(t)
+
+	rows, err := parseSample(strings.NewReader(`RespondentKey,region,age
+r001,north,34
+r002, south,
+`), "respondentkey")
+	assert.NoError(err)
+
+	assert.Len(rows, 2)
+	assert.Equal("r001", rows[0].respondentKey)
+	assert.Equal("north", rows[0].parameters["region"])
+	assert.Equal("34", rows[0].parameters["age"])
+	assert.Equal("south", rows[1].parameters["region"])
+	assert.Equal("", rows[1].parameters["age"])
+}
+
+func TestParseEmptySample(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := parseSample(strings.NewReader("respondentkey\n"), "respondentkey")
+	assert.Error(err)
+
+	_, err = parseSample(strings.NewReader(""), "respondentkey")
+	assert.Error(err)
+}
+
+func TestParseSampleWithoutKeyColumn(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := parseSample(strings.NewReader("key,region\nr001,north\n"), "respondentkey")
+	assert.EqualError(err, "sample file has no column 'respondentkey' with the respondent key")
+}
+
+func TestSampleURL(t *testing.T) {
+	assert := assert.New(t)
+
+	row := sampleRow{
+		respondentKey: "r001",
+		parameters:    map[string]string{"survey": "abc", "region": "north west", "age": "34"},
+		columns:       []string{"survey", "region", "age"},
+	}
+
+	result, err := getSampleURL("https://example.com/Interviews/{survey}?lang=en", row)
+	assert.NoError(err)
+	assert.Equal("https://example.com/Interviews/abc/r001?age=34&lang=en&region=north+west", result)
+}
+
+func TestSampleURLWithoutKey(t *testing.T) {
+	assert := assert.New(t)
+
+	row := sampleRow{
+		parameters: map[string]string{"region": "north"},
+		columns:    []string{"region"},
+	}
+
+	result, err := getSampleURL("https://example.com/Interviews/abc", row)
+	assert.NoError(err)
+	assert.Equal("https://example.com/Interviews/abc?region=north", result)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	interviewStateCompleted = "completed"
+	interviewStateAbandoned = "abandoned"
+	interviewStateErrored   = "errored"
+)
+
+type stateFile struct {
+	file   *os.File
+	states map[int]string
+	lock   sync.Mutex
+}
+
+// openStateFile reads the outcome of earlier runs from the file at path (if
+// it exists) and opens it for appending the outcome of this run.
+func openStateFile(path string) (*stateFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &stateFile{file: file, states: make(map[int]string)}
+	scanner := bufio.NewScanner(file)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			file.Close()
+			return nil, fmt.Errorf("invalid state file '%s' (line %d)", path, lineNumber)
+		}
+
+		number, err := strconv.Atoi(fields[1])
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid interview number in state file '%s' (line %d)", path, lineNumber)
+		}
+
+		// later lines win, so an interview that errored and was
+		// completed in a later run counts as completed
+		result.states[number] = fields[0]
+	}
+
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (state *stateFile) shouldRun(number int, erroredOnly bool) bool {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	switch state.states[number] {
+	case interviewStateCompleted, interviewStateAbandoned:
+		return false
+	case interviewStateErrored:
+		return true
+	default:
+		return !erroredOnly
+	}
+}
+
+// record appends the outcome of an interview and syncs it to disk, so a
+// rerun after a crash doesn't repeat it.
+func (state *stateFile) record(number int, respondentKey string, abandoned bool, err error) error {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	status := interviewStateCompleted
+	if err != nil {
+		status = interviewStateErrored
+	} else if abandoned {
+		status = interviewStateAbandoned
+	}
+
+	state.states[number] = status
+	if _, err := fmt.Fprintf(state.file, "%s\t%d\t%s\n", status, number, respondentKey); err != nil {
+		return fmt.Errorf("could not write interview %d to state file: %v", number, err)
+	}
+
+	if err := state.file.Sync(); err != nil {
+		return fmt.Errorf("could not write interview %d to state file: %v", number, err)
+	}
+
+	return nil
+}
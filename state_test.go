@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateFileResumesRun(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "state")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "run.state")
+
+	state, err := openStateFile(path)
+	assert.NoError(err)
+
+	assert.NoError(state.record(0, "0000", false, nil))
+	state.record(1, "0001", false, errors.New("validation error in interview (answer rejected)"))
+	state.record(2, "0002", false, errors.New("network"))
+	state.record(2, "0002", false, nil)
+	state.record(4, "0004", true, nil)
+	state.file.Close()
+
+	state, err = openStateFile(path)
+	assert.NoError(err)
+	defer state.file.Close()
+
+	assert.False(state.shouldRun(0, false), "completed interviews are skipped")
+	assert.True(state.shouldRun(1, false), "errored interviews are rerun")
+	assert.False(state.shouldRun(2, false), "latest state wins")
+	assert.True(state.shouldRun(3, false), "unstarted interviews are run")
+	assert.False(state.shouldRun(4, false), "abandoned interviews are skipped")
+
+	assert.True(state.shouldRun(1, true))
+	assert.False(state.shouldRun(3, true), "only errored interviews when requested")
+}
+
+func TestInvalidStateFile(t *testing.T) {
+	assert := assert.New(t)
+
+	file, err := ioutil.TempFile("", "state")
+	assert.NoError(err)
+	defer os.Remove(file.Name())
+
+	file.WriteString("completed\tabc\t0000\n")
+	file.Close()
+
+	_, err = openStateFile(file.Name())
+	assert.Error(err)
+}
+
+func TestStateFileReportsWriteErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "state")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	state, err := openStateFile(filepath.Join(dir, "run.state"))
+	assert.NoError(err)
+	state.file.Close()
+
+	err = state.record(0, "0000", false, nil)
+	assert.Error(err)
+	assert.Contains(err.Error(), "interview 0")
+
+	globalConfig = &globalConfiguration{}
+	currentStatus = &completeStatus{}
+	collectedErrors = &errorCollector{}
+
+	printOutputError(err)
+	assert.True(hasRunFailed(), "the run fails when its state can't be saved")
+	assert.Len(collectedErrors.summary(), 1)
+}
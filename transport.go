@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type transportConfiguration struct {
+	caCertFile     string
+	insecure       bool
+	clientCertFile string
+	clientKeyFile  string
+	proxyURL       string
+
+	// interviewURL is the interview the headers and authentication are
+	// meant for; requests to other hosts don't get them
+	interviewURL string
+
+	headers     []string
+	basicAuth   string
+	bearerToken string
+	userAgents  []string
+}
+
+// requestDecorator adds the configured headers and authentication to every
+// request a client makes to the interview host.
+type requestDecorator struct {
+	base       http.RoundTripper
+	host       string
+	headers    http.Header
+	userAgents []string
+	userAgent  string
+	basicAuth  []string
+	bearer     string
+}
+
+func (decorator *requestDecorator) RoundTrip(request *http.Request) (*http.Response, error) {
+	// a round tripper must not modify the request it was given
+	request = request.Clone(request.Context())
+
+	if decorator.userAgent != "" {
+		request.Header.Set("User-Agent", decorator.userAgent)
+	}
+
+	// credentials must not leak to other hosts, e.g. after a redirect
+	if !strings.EqualFold(request.URL.Host, decorator.host) {
+		return decorator.base.RoundTrip(request)
+	}
+
+	for key, values := range decorator.headers {
+		request.Header[key] = values
+	}
+	if decorator.basicAuth != nil {
+		request.SetBasicAuth(decorator.basicAuth[0], decorator.basicAuth[1])
+	}
+	if decorator.bearer != "" {
+		request.Header.Set("Authorization", "Bearer "+decorator.bearer)
+	}
+
+	return decorator.base.RoundTrip(request)
+}
+
+func buildTransport(config transportConfiguration) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.insecure}
+
+	if config.caCertFile != "" {
+		pem, err := ioutil.ReadFile(config.caCertFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in '%s'", config.caCertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.clientCertFile != "" || config.clientKeyFile != "" {
+		if config.clientCertFile == "" || config.clientKeyFile == "" {
+			return nil, fmt.Errorf("a client certificate requires both --client-cert and --client-key")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(config.clientCertFile, config.clientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	if config.proxyURL != "" {
+		proxy, err := url.Parse(config.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url '%s': %v", config.proxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	interviewURL, err := url.Parse(config.interviewURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interview url '%s': %v", config.interviewURL, err)
+	}
+
+	decorator := &requestDecorator{
+		base:       transport,
+		host:       interviewURL.Host,
+		headers:    http.Header{},
+		userAgents: config.userAgents,
+		bearer:     config.bearerToken,
+	}
+
+	for _, header := range config.headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header '%s', expected 'Name: value'", header)
+		}
+		decorator.headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	if config.basicAuth != "" {
+		parts := strings.SplitN(config.basicAuth, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid basic auth, expected 'user:password'")
+		}
+		decorator.basicAuth = parts
+	}
+
+	if config.basicAuth != "" && config.bearerToken != "" {
+		return nil, fmt.Errorf("cannot use basic auth and a bearer token at the same time")
+	}
+
+	return decorator, nil
+}
+
+// newHTTPClient creates a client using the configured transport. Every
+// client gets one user agent from the pool, so a worker looks like a single
+// browser for all of its requests.
+func newHTTPClient(jar http.CookieJar) http.Client {
+	client := http.Client{
+		Timeout: globalConfig.requestTimeout,
+		Jar:     jar,
+	}
+
+	if decorator, ok := globalConfig.transport.(*requestDecorator); ok {
+		clientDecorator := *decorator
+
+		if len(decorator.userAgents) > 0 {
+			clientDecorator.userAgent = decorator.userAgents[random.Intn(len(decorator.userAgents))]
+		}
+
+		client.Transport = &clientDecorator
+	}
+
+	return client
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTransport(t *testing.T, config transportConfiguration) {
+	transport, err := buildTransport(config)
+	assert.NoError(t, err)
+
+	globalConfig = &globalConfiguration{
+		requestTimeout: 5 * time.Second,
+		transport:      transport,
+	}
+}
+
+func TestTransportAddsHeadersAndAuth(t *testing.T) {
+	assert := assert.New(t)
+
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		received = request
+	}))
+	defer server.Close()
+
+	setupTransport(t, transportConfiguration{
+		interviewURL: server.URL + "/interview",
+		headers:      []string{"X-Test: yes", "X-Other:2"},
+		basicAuth:    "user:secret:with:colons",
+		userAgents:   []string{"test-agent"},
+	})
+
+	client := newHTTPClient(nil)
+	_, err := client.Get(server.URL)
+	assert.NoError(err)
+
+	assert.Equal("yes", received.Header.Get("X-Test"))
+	assert.Equal("2", received.Header.Get("X-Other"))
+	assert.Equal("test-agent", received.UserAgent())
+
+	user, password, ok := received.BasicAuth()
+	assert.True(ok)
+	assert.Equal("user", user)
+	assert.Equal("secret:with:colons", password)
+}
+
+func TestTransportBearerToken(t *testing.T) {
+	assert := assert.New(t)
+
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		received = request
+	}))
+	defer server.Close()
+
+	setupTransport(t, transportConfiguration{interviewURL: server.URL, bearerToken: "abc"})
+
+	client := newHTTPClient(nil)
+	_, err := client.Get(server.URL)
+	assert.NoError(err)
+
+	assert.Equal("Bearer abc", received.Header.Get("Authorization"))
+}
+
+func TestTransportOnlyAuthenticatesInterviewHost(t *testing.T) {
+	assert := assert.New(t)
+
+	var received *http.Request
+	other := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		received = request
+	}))
+	defer other.Close()
+
+	interview := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		http.Redirect(response, request, other.URL+"/elsewhere", http.StatusFound)
+	}))
+	defer interview.Close()
+
+	setupTransport(t, transportConfiguration{
+		interviewURL: interview.URL,
+		headers:      []string{"X-Test: yes"},
+		bearerToken:  "abc",
+		userAgents:   []string{"test-agent"},
+	})
+
+	client := newHTTPClient(nil)
+	_, err := client.Get(interview.URL)
+	assert.NoError(err)
+
+	assert.Equal("/elsewhere", received.URL.Path)
+	assert.Empty(received.Header.Get("Authorization"))
+	assert.Empty(received.Header.Get("X-Test"))
+	assert.Equal("test-agent", received.UserAgent())
+}
+
+func TestTransportInsecureSkipVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {}))
+	defer server.Close()
+
+	setupTransport(t, transportConfiguration{})
+	client := newHTTPClient(nil)
+	_, err := client.Get(server.URL)
+	assert.Error(err, "self-signed certificate is rejected by default")
+
+	setupTransport(t, transportConfiguration{insecure: true})
+	client = newHTTPClient(nil)
+	_, err = client.Get(server.URL)
+	assert.NoError(err)
+}
+
+func TestInvalidTransportOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, config := range []transportConfiguration{
+		{headers: []string{"no colon"}},
+		{basicAuth: "user"},
+		{basicAuth: "user:pass", bearerToken: "abc"},
+		{clientCertFile: "cert.pem"},
+		{proxyURL: "://invalid"},
+		{caCertFile: "does-not-exist.pem"},
+		{interviewURL: "://invalid"},
+	} {
+		_, err := buildTransport(config)
+		assert.Error(err)
+	}
+}